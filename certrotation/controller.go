@@ -0,0 +1,264 @@
+package certrotation
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/appscode/go/log"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// Controller watches the guard-pki Secret and keeps the CA bundle, serving
+// certificate, and client CA rotated, so a guard Deployment no longer needs
+// a static, manually regenerated PKI Secret. It hot-reloads the running
+// server's TLS config in place instead of relying on a pod restart.
+type Controller struct {
+	opts      Options
+	namespace string
+	client    kubernetes.Interface
+
+	mu          sync.RWMutex
+	certificate *tls.Certificate
+	onReload    []func(*tls.Certificate)
+}
+
+// NewController creates a rotation controller for the guard-pki Secret (and
+// the CA bundle ConfigMap, when configured) in namespace.
+func NewController(client kubernetes.Interface, namespace string, opts Options) *Controller {
+	return &Controller{
+		opts:      opts,
+		namespace: namespace,
+		client:    client,
+	}
+}
+
+// OnReload registers a callback invoked with the current leaf certificate
+// every time it is published: on each periodic load of the guard-pki Secret
+// and each reconcile, whether or not anything was rotated. This lets the
+// running HTTPS server swap tls.Config.Certificates without a restart.
+func (c *Controller) OnReload(fn func(*tls.Certificate)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onReload = append(c.onReload, fn)
+}
+
+// GetCertificate is suitable for tls.Config.GetCertificate, always returning
+// the most recently rotated leaf certificate.
+func (c *Controller) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.certificate, nil
+}
+
+// Run blocks until stopCh is closed. Every replica runs a read-only loader
+// that keeps GetCertificate serving the current leaf from the guard-pki
+// Secret, regardless of leadership — TLS must work on every pod, not just
+// the leader. Only the rotation/write path (minting and persisting new
+// certs) is gated behind the guard-pki-rotation lease, so replicas don't
+// race to rewrite the Secret.
+func (c *Controller) Run(stopCh <-chan struct{}) {
+	go c.runCertLoader(stopCh)
+
+	identity, err := os.Hostname()
+	if err != nil || identity == "" {
+		identity = fmt.Sprintf("guard-%d", os.Getpid())
+	}
+	lock := &resourcelock.ConfigMapLock{
+		ConfigMapMeta: metav1.ObjectMeta{
+			Name:      "guard-pki-rotation",
+			Namespace: c.namespace,
+		},
+		Client: c.client.CoreV1(),
+		LockConfig: resourcelock.ResourceLockConfig{
+			Identity: identity,
+		},
+	}
+	leaderelection.RunOrDie(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   2 * time.Second,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				c.runRotationLoop(stop)
+			},
+			OnStoppedLeading: func() {
+				log.Infoln("certrotation: lost leadership, stopping rotation loop")
+			},
+		},
+	})
+	<-stopCh
+}
+
+// runCertLoader periodically loads the guard-pki Secret and publishes its
+// leaf certificate so GetCertificate has something to serve. It runs on
+// every replica unconditionally, independent of leader election, since
+// every replica's HTTPS listener needs the current certificate.
+func (c *Controller) runCertLoader(stopCh <-chan struct{}) {
+	wait := time.NewTicker(time.Minute)
+	defer wait.Stop()
+	for {
+		if err := c.loadAndPublish(); err != nil {
+			log.Errorf("certrotation: failed to load %s: %v", c.opts.SecretName, err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-wait.C:
+		}
+	}
+}
+
+// loadAndPublish is the read-only half of reconcile: it loads the current
+// guard-pki Secret and publishes its leaf, without attempting to bootstrap
+// or rotate anything.
+func (c *Controller) loadAndPublish() error {
+	secret, err := c.client.CoreV1().Secrets(c.namespace).Get(c.opts.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	state, err := parsePKIState(secret)
+	if err != nil {
+		return err
+	}
+	c.publish(state)
+	return nil
+}
+
+// runRotationLoop periodically checks the guard-pki Secret and rotates the
+// leaf and/or CA signer once RefreshPercent of their lifetime has elapsed.
+// This only runs on the current leader.
+func (c *Controller) runRotationLoop(stopCh <-chan struct{}) {
+	wait := time.NewTicker(time.Minute)
+	defer wait.Stop()
+	for {
+		if err := c.reconcile(); err != nil {
+			log.Errorf("certrotation: reconcile failed: %v", err)
+		}
+		select {
+		case <-stopCh:
+			return
+		case <-wait.C:
+		}
+	}
+}
+
+// reconcile loads the guard-pki Secret, rotates the leaf and/or CA signer if
+// due, and publishes the result to the CA bundle ConfigMap and any
+// registered OnReload callbacks. It is idempotent: calling it with nothing
+// due to rotate is a no-op beyond the Secret/ConfigMap reads.
+func (c *Controller) reconcile() error {
+	secret, err := c.client.CoreV1().Secrets(c.namespace).Get(c.opts.SecretName, metav1.GetOptions{})
+	if kerrors.IsNotFound(err) {
+		return c.bootstrap()
+	}
+	if err != nil {
+		return err
+	}
+
+	state, err := parsePKIState(secret)
+	if err != nil {
+		return err
+	}
+
+	// parsePKIState only ever knows about the signer currently in the
+	// Secret; recover the rest of the staleSigners history (and their real
+	// supersededAt times) from the CA bundle ConfigMap a previous
+	// reconcile() persisted it to, so pruneExpiredBundleEntries isn't
+	// blind on every call but the one that just rotated a signer.
+	bundle, err := c.client.CoreV1().ConfigMaps(c.namespace).Get(c.opts.CABundleConfigMapName, metav1.GetOptions{})
+	if err != nil && !kerrors.IsNotFound(err) {
+		return err
+	}
+	if err == nil {
+		staleSigners, err := parseStaleSigners(bundle)
+		if err != nil {
+			return err
+		}
+		state.staleSigners = staleSigners
+	}
+
+	if state.leafDueForRotation(c.opts.RefreshPercent) {
+		if err := c.rotateLeaf(state); err != nil {
+			return err
+		}
+	}
+	if state.signerDueForRotation(c.opts.RefreshPercent) {
+		if err := c.rotateSigner(state); err != nil {
+			return err
+		}
+	}
+	if err := c.pruneExpiredBundleEntries(state); err != nil {
+		return err
+	}
+
+	c.publish(state)
+	return nil
+}
+
+// bootstrap creates the initial guard-pki Secret and CA bundle ConfigMap
+// when none exist yet, so a fresh install no longer needs `guard init ca`
+// and `guard init server` run out of band.
+func (c *Controller) bootstrap() error {
+	state, err := newPKIState(c.opts)
+	if err != nil {
+		return err
+	}
+	if _, err := c.client.CoreV1().Secrets(c.namespace).Create(state.toSecret(c.namespace, c.opts.SecretName)); err != nil {
+		return err
+	}
+	if _, err := c.client.CoreV1().ConfigMaps(c.namespace).Create(state.toCABundleConfigMap(c.namespace, c.opts.CABundleConfigMapName)); err != nil {
+		return err
+	}
+	c.publish(state)
+	return nil
+}
+
+func (c *Controller) rotateLeaf(state *pkiState) error {
+	log.Infoln("certrotation: rotating serving certificate")
+	if err := state.rotateLeaf(c.opts.LeafLifetime); err != nil {
+		return err
+	}
+	_, err := c.client.CoreV1().Secrets(c.namespace).Update(state.toSecret(c.namespace, c.opts.SecretName))
+	return err
+}
+
+func (c *Controller) rotateSigner(state *pkiState) error {
+	log.Infoln("certrotation: rotating CA signer")
+	if err := state.rotateSigner(c.opts.SignerLifetime); err != nil {
+		return err
+	}
+	if _, err := c.client.CoreV1().Secrets(c.namespace).Update(state.toSecret(c.namespace, c.opts.SecretName)); err != nil {
+		return err
+	}
+	_, err := c.client.CoreV1().ConfigMaps(c.namespace).Update(state.toCABundleConfigMap(c.namespace, c.opts.CABundleConfigMapName))
+	return err
+}
+
+// pruneExpiredBundleEntries removes CA signers from the bundle ConfigMap
+// once they have been superseded for longer than opts.Overlap.
+func (c *Controller) pruneExpiredBundleEntries(state *pkiState) error {
+	if !state.pruneStaleSigners(c.opts.Overlap) {
+		return nil
+	}
+	_, err := c.client.CoreV1().ConfigMaps(c.namespace).Update(state.toCABundleConfigMap(c.namespace, c.opts.CABundleConfigMapName))
+	return err
+}
+
+func (c *Controller) publish(state *pkiState) {
+	cert := state.tlsCertificate()
+	c.mu.Lock()
+	c.certificate = cert
+	callbacks := append([]func(*tls.Certificate){}, c.onReload...)
+	c.mu.Unlock()
+	for _, fn := range callbacks {
+		fn(cert)
+	}
+}
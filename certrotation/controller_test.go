@@ -0,0 +1,78 @@
+package certrotation
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// TestReconcilePrunesStaleSignerAcrossCalls guards against the bug where
+// every reconcile() rebuilt pkiState purely from parsePKIState(secret),
+// which never restores staleSigners from anywhere durable — so a signer
+// superseded in one reconcile() call could never be pruned in a later one,
+// even once it was well past Overlap.
+func TestReconcilePrunesStaleSignerAcrossCalls(t *testing.T) {
+	opts := NewOptions()
+	opts.Overlap = time.Hour
+	client := fake.NewSimpleClientset()
+	c := NewController(client, "guard", opts)
+
+	if err := c.bootstrap(); err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+
+	secret, err := client.CoreV1().Secrets("guard").Get(opts.SecretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get secret: %v", err)
+	}
+	state, err := parsePKIState(secret)
+	if err != nil {
+		t.Fatalf("parsePKIState: %v", err)
+	}
+	if err := c.rotateSigner(state); err != nil {
+		t.Fatalf("rotateSigner: %v", err)
+	}
+
+	// Simulate the signer rotateSigner just superseded having actually been
+	// superseded well over an hour ago, as a fresh reconcile() call would
+	// see it (a new pkiState, parsed only from the Secret, has no idea how
+	// long ago that was on its own).
+	bundle, err := client.CoreV1().ConfigMaps("guard").Get(opts.CABundleConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get bundle configmap: %v", err)
+	}
+	records := []staleSignerRecord{{
+		CertPEM:      state.staleSigners[0].certPEM,
+		SupersededAt: time.Now().Add(-2 * time.Hour),
+	}}
+	backdated, err := json.Marshal(records)
+	if err != nil {
+		t.Fatalf("marshal records: %v", err)
+	}
+	bundle.Data[staleSignersKey] = string(backdated)
+	if _, err := client.CoreV1().ConfigMaps("guard").Update(bundle); err != nil {
+		t.Fatalf("update bundle configmap: %v", err)
+	}
+
+	// A fresh reconcile() call, with no in-memory pkiState carried over
+	// from the rotateSigner call above, must still recover and prune the
+	// now overlap-expired stale signer.
+	if err := c.reconcile(); err != nil {
+		t.Fatalf("reconcile: %v", err)
+	}
+
+	after, err := client.CoreV1().ConfigMaps("guard").Get(opts.CABundleConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("get bundle configmap after reconcile: %v", err)
+	}
+	remaining, err := parseStaleSigners(after)
+	if err != nil {
+		t.Fatalf("parseStaleSigners after reconcile: %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Fatalf("expected reconcile() to prune the overlap-expired stale signer, got %d remaining", len(remaining))
+	}
+}
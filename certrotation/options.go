@@ -0,0 +1,94 @@
+package certrotation
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+// Options configures the in-cluster rotation of the CA bundle and serving
+// certificate used by the guard webhook, replacing the one-shot
+// `guard init ca` / `guard init server` flow with a controller that keeps
+// the PKI fresh for the lifetime of the Deployment.
+type Options struct {
+	Enabled bool
+
+	// SignerLifetime is how long a freshly minted CA signer is valid for.
+	SignerLifetime time.Duration
+	// LeafLifetime is how long a freshly minted serving (leaf) certificate
+	// is valid for.
+	LeafLifetime time.Duration
+	// RefreshPercent is the fraction of a certificate's lifetime that must
+	// elapse before it is rotated, e.g. 0.8 rotates at 80% of lifetime.
+	RefreshPercent float64
+	// Overlap is how long an old CA signer is kept in the CA bundle
+	// ConfigMap after being superseded, so that already-issued leafs and
+	// existing client trust stores remain valid during the transition.
+	Overlap time.Duration
+
+	// SecretName is the Secret holding the CA bundle and serving cert/key,
+	// watched and updated in place by the rotation controller.
+	SecretName string
+	// CABundleConfigMapName is the ConfigMap that accumulates CA signers
+	// during their overlap period, for consumers (e.g. the apiserver's
+	// webhook client CA) that read trust roots from a ConfigMap rather than
+	// the guard-pki Secret directly.
+	CABundleConfigMapName string
+}
+
+func NewOptions() Options {
+	return Options{
+		SignerLifetime:        365 * 24 * time.Hour,
+		LeafLifetime:          90 * 24 * time.Hour,
+		RefreshPercent:        0.8,
+		Overlap:               24 * time.Hour,
+		SecretName:            "guard-pki",
+		CABundleConfigMapName: "guard-ca-bundle",
+	}
+}
+
+func (o *Options) AddFlags(fs *pflag.FlagSet) {
+	fs.BoolVar(&o.Enabled, "tls.auto-rotate", o.Enabled, "Automatically rotate the CA bundle and serving certificate in-cluster instead of requiring `guard init ca`/`guard init server`")
+	fs.DurationVar(&o.SignerLifetime, "tls.signer-lifetime", o.SignerLifetime, "Validity period for a newly minted CA signer")
+	fs.DurationVar(&o.LeafLifetime, "tls.leaf-lifetime", o.LeafLifetime, "Validity period for a newly minted serving certificate")
+	fs.Float64Var(&o.RefreshPercent, "tls.refresh-percent", o.RefreshPercent, "Fraction of a certificate's lifetime that must elapse before it is rotated")
+	fs.DurationVar(&o.Overlap, "tls.ca-overlap", o.Overlap, "How long a superseded CA signer is kept in the CA bundle before being removed")
+	fs.StringVar(&o.SecretName, "tls.pki-secret-name", o.SecretName, "Name of the Secret holding the CA bundle and serving cert/key, watched and updated in place by the rotation controller")
+	fs.StringVar(&o.CABundleConfigMapName, "tls.ca-bundle-configmap-name", o.CABundleConfigMapName, "Name of the ConfigMap that accumulates CA signers during their overlap period")
+}
+
+func (o Options) ToArgs() []string {
+	var args []string
+	if !o.Enabled {
+		return args
+	}
+	args = append(args, "--tls.auto-rotate")
+	if o.SignerLifetime != 0 {
+		args = append(args, fmt.Sprintf("--tls.signer-lifetime=%s", o.SignerLifetime))
+	}
+	if o.LeafLifetime != 0 {
+		args = append(args, fmt.Sprintf("--tls.leaf-lifetime=%s", o.LeafLifetime))
+	}
+	if o.RefreshPercent != 0 {
+		args = append(args, fmt.Sprintf("--tls.refresh-percent=%v", o.RefreshPercent))
+	}
+	if o.Overlap != 0 {
+		args = append(args, fmt.Sprintf("--tls.ca-overlap=%s", o.Overlap))
+	}
+	if o.SecretName != "" {
+		args = append(args, fmt.Sprintf("--tls.pki-secret-name=%s", o.SecretName))
+	}
+	if o.CABundleConfigMapName != "" {
+		args = append(args, fmt.Sprintf("--tls.ca-bundle-configmap-name=%s", o.CABundleConfigMapName))
+	}
+	return args
+}
+
+func (o *Options) Validate() []error {
+	var errs []error
+	if o.RefreshPercent <= 0 || o.RefreshPercent >= 1 {
+		errs = append(errs, fmt.Errorf("tls.refresh-percent must be between 0 and 1, got %v", o.RefreshPercent))
+	}
+	return errs
+}
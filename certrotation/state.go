@@ -0,0 +1,289 @@
+package certrotation
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// staleSignersKey is the CA bundle ConfigMap data key that durably records
+// staleSigner.supersededAt for every signer in the bundle. Without it,
+// reconcile() can only ever recover staleSigners from parsePKIState, which
+// only knows about the current signer in the guard-pki Secret — a fresh
+// reconcile() call (or a leader handoff) would otherwise forget every
+// signer's supersededAt and pruneExpiredBundleEntries could never fire.
+const staleSignersKey = "stale-signers.json"
+
+// certKeyPair is a generated or parsed certificate/key pair, kept alongside
+// its PEM encoding so it can be round-tripped through a Secret unchanged.
+type certKeyPair struct {
+	cert    *x509.Certificate
+	key     *rsa.PrivateKey
+	certPEM []byte
+	keyPEM  []byte
+}
+
+// pkiState is the in-memory view of the guard-pki Secret: the active CA
+// signer, the active serving leaf, and any signers kept around for Overlap
+// so already-issued leafs and existing client trust stores keep working
+// through a rotation.
+type pkiState struct {
+	signer       *certKeyPair
+	leaf         *certKeyPair
+	leafLifetime time.Duration
+	staleSigners []staleSigner
+}
+
+// staleSigner is a CA signer superseded by rotateSigner but still accepted
+// for Overlap after supersession, so certs already issued under it (and
+// clients that trust it) keep working until it's pruned. Only certPEM is
+// kept, not the private key: nothing re-signs under a stale signer.
+type staleSigner struct {
+	certPEM      []byte
+	supersededAt time.Time
+}
+
+// staleSignerRecord is the JSON form staleSigner is persisted as, under
+// staleSignersKey in the CA bundle ConfigMap.
+type staleSignerRecord struct {
+	CertPEM      []byte    `json:"certPEM"`
+	SupersededAt time.Time `json:"supersededAt"`
+}
+
+// parseStaleSigners recovers the staleSigners history durably recorded in
+// the CA bundle ConfigMap by a previous reconcile(), so pruneStaleSigners
+// can still tell a real overlap-expired signer apart from one just rotated
+// in this call.
+func parseStaleSigners(cm *core.ConfigMap) ([]staleSigner, error) {
+	raw := cm.Data[staleSignersKey]
+	if raw == "" {
+		return nil, nil
+	}
+	var records []staleSignerRecord
+	if err := json.Unmarshal([]byte(raw), &records); err != nil {
+		return nil, fmt.Errorf("certrotation: failed to parse stale signers from %s: %v", cm.Name, err)
+	}
+	signers := make([]staleSigner, 0, len(records))
+	for _, r := range records {
+		signers = append(signers, staleSigner{certPEM: r.CertPEM, supersededAt: r.SupersededAt})
+	}
+	return signers, nil
+}
+
+func newPKIState(opts Options) (*pkiState, error) {
+	signer, err := newSelfSignedCA("guard-ca", opts.SignerLifetime)
+	if err != nil {
+		return nil, fmt.Errorf("certrotation: failed to generate CA signer: %v", err)
+	}
+	s := &pkiState{signer: signer, leafLifetime: opts.LeafLifetime}
+	if err := s.rotateLeaf(opts.LeafLifetime); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func parsePKIState(secret *core.Secret) (*pkiState, error) {
+	signer, err := parseCertKeyPair(secret.Data["ca.crt"], secret.Data["ca.key"])
+	if err != nil {
+		return nil, fmt.Errorf("certrotation: failed to parse CA signer from %s: %v", secret.Name, err)
+	}
+	leaf, err := parseCertKeyPair(secret.Data["tls.crt"], secret.Data["tls.key"])
+	if err != nil {
+		return nil, fmt.Errorf("certrotation: failed to parse serving cert from %s: %v", secret.Name, err)
+	}
+	return &pkiState{signer: signer, leaf: leaf, leafLifetime: leaf.cert.NotAfter.Sub(leaf.cert.NotBefore)}, nil
+}
+
+func (s *pkiState) leafDueForRotation(refreshPercent float64) bool {
+	return dueForRotation(s.leaf.cert, refreshPercent)
+}
+
+func (s *pkiState) signerDueForRotation(refreshPercent float64) bool {
+	return dueForRotation(s.signer.cert, refreshPercent)
+}
+
+func dueForRotation(cert *x509.Certificate, refreshPercent float64) bool {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	refreshAt := cert.NotBefore.Add(time.Duration(float64(lifetime) * refreshPercent))
+	return time.Now().After(refreshAt)
+}
+
+func (s *pkiState) rotateLeaf(lifetime time.Duration) error {
+	leaf, err := newSignedCert(s.signer, "guard", lifetime)
+	if err != nil {
+		return fmt.Errorf("certrotation: failed to issue serving certificate: %v", err)
+	}
+	s.leaf = leaf
+	s.leafLifetime = lifetime
+	return nil
+}
+
+// rotateSigner mints a new CA signer, re-issues the leaf under it, and keeps
+// the superseded signer around (pruned later via pruneStaleSigners) so
+// certificates issued under it remain trusted during the overlap period.
+func (s *pkiState) rotateSigner(lifetime time.Duration) error {
+	s.staleSigners = append(s.staleSigners, staleSigner{certPEM: s.signer.certPEM, supersededAt: time.Now()})
+	signer, err := newSelfSignedCA("guard-ca", lifetime)
+	if err != nil {
+		return fmt.Errorf("certrotation: failed to generate CA signer: %v", err)
+	}
+	s.signer = signer
+	return s.rotateLeaf(s.leafLifetime)
+}
+
+// pruneStaleSigners drops signers superseded for longer than overlap.
+// Returns true if anything was removed.
+func (s *pkiState) pruneStaleSigners(overlap time.Duration) bool {
+	var kept []staleSigner
+	for _, ss := range s.staleSigners {
+		if time.Since(ss.supersededAt) < overlap {
+			kept = append(kept, ss)
+		}
+	}
+	changed := len(kept) != len(s.staleSigners)
+	s.staleSigners = kept
+	return changed
+}
+
+func (s *pkiState) tlsCertificate() *tls.Certificate {
+	return &tls.Certificate{
+		Certificate: [][]byte{s.leaf.cert.Raw, s.signer.cert.Raw},
+		PrivateKey:  s.leaf.key,
+		Leaf:        s.leaf.cert,
+	}
+}
+
+func (s *pkiState) toSecret(namespace, name string) *core.Secret {
+	return &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "guard"},
+		},
+		Data: map[string][]byte{
+			"ca.crt":  s.signer.certPEM,
+			"ca.key":  s.signer.keyPEM,
+			"tls.crt": s.leaf.certPEM,
+			"tls.key": s.leaf.keyPEM,
+		},
+	}
+}
+
+// toCABundleConfigMap renders the active signer plus any signers still
+// within their overlap window, so consumers that trust this bundle (e.g.
+// the apiserver webhook client CA) see every signer capable of validating a
+// currently live certificate.
+func (s *pkiState) toCABundleConfigMap(namespace, name string) *core.ConfigMap {
+	bundle := string(s.signer.certPEM)
+	records := make([]staleSignerRecord, 0, len(s.staleSigners))
+	for _, ss := range s.staleSigners {
+		bundle += string(ss.certPEM)
+		records = append(records, staleSignerRecord{CertPEM: ss.certPEM, SupersededAt: ss.supersededAt})
+	}
+	// staleSignerRecord only holds []byte and time.Time fields, so this
+	// Marshal cannot fail.
+	staleJSON, _ := json.Marshal(records)
+	return &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "guard"},
+		},
+		Data: map[string]string{
+			"ca-bundle.crt": bundle,
+			staleSignersKey: string(staleJSON),
+		},
+	}
+}
+
+func newSelfSignedCA(cn string, lifetime time.Duration) (*certKeyPair, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             now,
+		NotAfter:              now.Add(lifetime),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return toCertKeyPair(der, key)
+}
+
+func newSignedCert(signer *certKeyPair, cn string, lifetime time.Duration) (*certKeyPair, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := newSerialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    now,
+		NotAfter:     now.Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{cn},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer.cert, &key.PublicKey, signer.key)
+	if err != nil {
+		return nil, err
+	}
+	return toCertKeyPair(der, key)
+}
+
+func newSerialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
+
+func toCertKeyPair(der []byte, key *rsa.PrivateKey) (*certKeyPair, error) {
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+	return parseCertKeyPair(certPEM, keyPEM)
+}
+
+func parseCertKeyPair(certPEM, keyPEM []byte) (*certKeyPair, error) {
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return nil, fmt.Errorf("failed to decode certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return nil, fmt.Errorf("failed to decode key PEM")
+	}
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &certKeyPair{cert: cert, key: key, certPEM: certPEM, keyPEM: keyPEM}, nil
+}
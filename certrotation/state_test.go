@@ -0,0 +1,120 @@
+package certrotation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDueForRotation(t *testing.T) {
+	signer, err := newSelfSignedCA("guard-ca", time.Hour)
+	if err != nil {
+		t.Fatalf("newSelfSignedCA: %v", err)
+	}
+
+	if dueForRotation(signer.cert, 0.8) {
+		t.Fatalf("freshly minted cert should not be due for rotation")
+	}
+
+	// Simulate a certificate that is already 90% through its lifetime.
+	signer.cert.NotBefore = time.Now().Add(-54 * time.Minute)
+	signer.cert.NotAfter = signer.cert.NotBefore.Add(time.Hour)
+	if !dueForRotation(signer.cert, 0.8) {
+		t.Fatalf("cert at 90%% of lifetime should be due for rotation at 80%% threshold")
+	}
+}
+
+func TestRotateLeafAndSigner(t *testing.T) {
+	opts := NewOptions()
+	state, err := newPKIState(opts)
+	if err != nil {
+		t.Fatalf("newPKIState: %v", err)
+	}
+
+	oldSigner := state.signer
+	if err := state.rotateSigner(opts.SignerLifetime); err != nil {
+		t.Fatalf("rotateSigner: %v", err)
+	}
+	if state.signer == oldSigner {
+		t.Fatalf("expected a new signer after rotateSigner")
+	}
+	if len(state.staleSigners) != 1 || string(state.staleSigners[0].certPEM) != string(oldSigner.certPEM) {
+		t.Fatalf("expected the old signer to be kept as stale, got %+v", state.staleSigners)
+	}
+
+	oldLeaf := state.leaf
+	if err := state.rotateLeaf(opts.LeafLifetime); err != nil {
+		t.Fatalf("rotateLeaf: %v", err)
+	}
+	if state.leaf == oldLeaf {
+		t.Fatalf("expected a new leaf after rotateLeaf")
+	}
+}
+
+func TestPruneStaleSigners(t *testing.T) {
+	opts := NewOptions()
+	state, err := newPKIState(opts)
+	if err != nil {
+		t.Fatalf("newPKIState: %v", err)
+	}
+	if err := state.rotateSigner(opts.SignerLifetime); err != nil {
+		t.Fatalf("rotateSigner: %v", err)
+	}
+	if len(state.staleSigners) != 1 {
+		t.Fatalf("expected 1 stale signer, got %d", len(state.staleSigners))
+	}
+
+	// Not yet past the overlap window: nothing pruned.
+	if changed := state.pruneStaleSigners(time.Hour); changed {
+		t.Fatalf("did not expect a stale signer within the overlap window to be pruned")
+	}
+	if len(state.staleSigners) != 1 {
+		t.Fatalf("expected the stale signer to remain, got %d", len(state.staleSigners))
+	}
+
+	// Backdate it past the overlap window.
+	state.staleSigners[0].supersededAt = time.Now().Add(-2 * time.Hour)
+	if changed := state.pruneStaleSigners(time.Hour); !changed {
+		t.Fatalf("expected the stale signer past the overlap window to be pruned")
+	}
+	if len(state.staleSigners) != 0 {
+		t.Fatalf("expected no stale signers left, got %d", len(state.staleSigners))
+	}
+}
+
+// TestStaleSignersSurviveConfigMapRoundTrip guards against the bug where a
+// fresh reconcile() rebuilt pkiState purely via parsePKIState(secret), which
+// never restores staleSigners from anywhere durable — so an overlap-expired
+// signer could never be detected once its supersededAt was more than one
+// reconcile() call in the past.
+func TestStaleSignersSurviveConfigMapRoundTrip(t *testing.T) {
+	opts := NewOptions()
+	state, err := newPKIState(opts)
+	if err != nil {
+		t.Fatalf("newPKIState: %v", err)
+	}
+	if err := state.rotateSigner(opts.SignerLifetime); err != nil {
+		t.Fatalf("rotateSigner: %v", err)
+	}
+	state.staleSigners[0].supersededAt = time.Now().Add(-2 * time.Hour)
+
+	cm := state.toCABundleConfigMap("guard", opts.CABundleConfigMapName)
+
+	// Simulate a fresh reconcile() that only has the Secret, plus the
+	// durable bundle ConfigMap, to rebuild state from.
+	restored := &pkiState{signer: state.signer, leaf: state.leaf, leafLifetime: state.leafLifetime}
+	staleSigners, err := parseStaleSigners(cm)
+	if err != nil {
+		t.Fatalf("parseStaleSigners: %v", err)
+	}
+	restored.staleSigners = staleSigners
+
+	if len(restored.staleSigners) != 1 {
+		t.Fatalf("expected 1 stale signer restored from the ConfigMap, got %d", len(restored.staleSigners))
+	}
+	if changed := restored.pruneStaleSigners(time.Hour); !changed {
+		t.Fatalf("expected the restored stale signer's real supersededAt to be past the overlap window")
+	}
+	if len(restored.staleSigners) != 0 {
+		t.Fatalf("expected no stale signers left after pruning, got %d", len(restored.staleSigners))
+	}
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/appscode/go/types"
 	v "github.com/appscode/go/version"
 	"github.com/appscode/guard/azure"
+	"github.com/appscode/guard/certrotation"
 	"github.com/appscode/guard/google"
 	"github.com/appscode/guard/ldap"
 	"github.com/appscode/guard/server"
@@ -36,10 +37,12 @@ type options struct {
 	privateRegistry string
 	imagePullSecret string
 
-	Token  token.Options
-	Google google.Options
-	Azure  azure.Options
-	LDAP   ldap.Options
+	Token        token.Options
+	Google       google.Options
+	Azure        azure.Options
+	LDAP         ldap.Options
+	CertRotation certrotation.Options
+	Metrics      server.MetricsOptions
 }
 
 func NewCmdInstaller() *cobra.Command {
@@ -48,6 +51,7 @@ func NewCmdInstaller() *cobra.Command {
 		addr:            "10.96.10.96:443",
 		privateRegistry: "appscode",
 		runOnMaster:     true,
+		CertRotation:    certrotation.NewOptions(),
 	}
 	cmd := &cobra.Command{
 		Use:               "installer",
@@ -63,24 +67,38 @@ func NewCmdInstaller() *cobra.Command {
 				log.Fatalf("Guard server port is invalid. Reason: %v.", err)
 			}
 
-			store, err := certstore.NewCertStore(afero.NewOsFs(), filepath.Join(rootDir, "pki"))
-			if err != nil {
-				log.Fatalf("Failed to create certificate store. Reason: %v.", err)
-			}
-			if !store.PairExists("ca") {
-				log.Fatalf("CA certificates not found in %s. Run `guard init ca`", store.Location())
+			for _, err := range opts.LDAP.Validate() {
+				log.Fatalf("Invalid LDAP options. Reason: %v.", err)
 			}
-			if !store.PairExists("server") {
-				log.Fatalf("Server certificate not found in %s. Run `guard init server`", store.Location())
+			for _, err := range opts.CertRotation.Validate() {
+				log.Fatalf("Invalid cert-rotation options. Reason: %v.", err)
 			}
 
-			caCert, _, err := store.ReadBytes("ca")
-			if err != nil {
-				log.Fatalf("Failed to load ca certificate. Reason: %v.", err)
-			}
-			serverCert, serverKey, err := store.ReadBytes("server")
-			if err != nil {
-				log.Fatalf("Failed to load ca certificate. Reason: %v.", err)
+			// When certrotation is enabled, the in-cluster controller bootstraps
+			// and owns the guard-pki Secret itself, so the one-shot
+			// `guard init ca`/`guard init server` flow and its local pki dir
+			// are not required.
+			var caCert, serverCert, serverKey []byte
+			if !opts.CertRotation.Enabled {
+				store, err := certstore.NewCertStore(afero.NewOsFs(), filepath.Join(rootDir, "pki"))
+				if err != nil {
+					log.Fatalf("Failed to create certificate store. Reason: %v.", err)
+				}
+				if !store.PairExists("ca") {
+					log.Fatalf("CA certificates not found in %s. Run `guard init ca`", store.Location())
+				}
+				if !store.PairExists("server") {
+					log.Fatalf("Server certificate not found in %s. Run `guard init server`", store.Location())
+				}
+
+				caCert, _, err = store.ReadBytes("ca")
+				if err != nil {
+					log.Fatalf("Failed to load ca certificate. Reason: %v.", err)
+				}
+				serverCert, serverKey, err = store.ReadBytes("server")
+				if err != nil {
+					log.Fatalf("Failed to load ca certificate. Reason: %v.", err)
+				}
 			}
 
 			var buf bytes.Buffer
@@ -116,12 +134,30 @@ func NewCmdInstaller() *cobra.Command {
 			buf.Write(data)
 			buf.WriteString("---\n")
 
-			data, err = meta.MarshalToYAML(newSecret(opts.namespace, serverCert, serverKey, caCert), core.SchemeGroupVersion)
-			if err != nil {
-				log.Fatalln(err)
+			if !opts.CertRotation.Enabled {
+				data, err = meta.MarshalToYAML(newSecret(opts.namespace, serverCert, serverKey, caCert), core.SchemeGroupVersion)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				buf.Write(data)
+				buf.WriteString("---\n")
+			}
+
+			if opts.CertRotation.Enabled {
+				data, err = meta.MarshalToYAML(newRole(opts.namespace, opts.CertRotation), rbac.SchemeGroupVersion)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				buf.Write(data)
+				buf.WriteString("---\n")
+
+				data, err = meta.MarshalToYAML(newRoleBinding(opts.namespace), rbac.SchemeGroupVersion)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				buf.Write(data)
+				buf.WriteString("---\n")
 			}
-			buf.Write(data)
-			buf.WriteString("---\n")
 
 			secretData := map[string][]byte{}
 			if opts.Token.AuthFile != "" {
@@ -165,6 +201,23 @@ func NewCmdInstaller() *cobra.Command {
 				buf.WriteString("---\n")
 			}
 
+			if opts.LDAP.ConfigFile != "" {
+				if _, err := ldap.LoadConfigFile(opts.LDAP.ConfigFile); err != nil {
+					log.Fatalln(err)
+				}
+				cfg, err := ioutil.ReadFile(opts.LDAP.ConfigFile)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				configData := map[string][]byte{"config.yaml": cfg}
+				data, err = meta.MarshalToYAML(newSecretForLDAPConfig(opts.namespace, configData), core.SchemeGroupVersion)
+				if err != nil {
+					log.Fatalln(err)
+				}
+				buf.Write(data)
+				buf.WriteString("---\n")
+			}
+
 			data, err = meta.MarshalToYAML(newDeployment(opts), apps.SchemeGroupVersion)
 			if err != nil {
 				log.Fatalln(err)
@@ -192,6 +245,8 @@ func NewCmdInstaller() *cobra.Command {
 	opts.Google.AddFlags(cmd.Flags())
 	opts.Azure.AddFlags(cmd.Flags())
 	opts.LDAP.AddFlags(cmd.Flags())
+	opts.CertRotation.AddFlags(cmd.Flags())
+	opts.Metrics.AddFlags(cmd.Flags())
 	return cmd
 }
 
@@ -223,6 +278,21 @@ func newSecret(namespace string, cert, key, caCert []byte) runtime.Object {
 	}
 }
 
+// metricsContainerPort returns the port to open on the container for
+// --server.metrics-addr, so the generated manifest documents the port the
+// operator actually configured rather than always advertising the default.
+func metricsContainerPort(metricsAddr string) int32 {
+	_, port, err := net.SplitHostPort(metricsAddr)
+	if err != nil {
+		return server.MetricsPort
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil {
+		return server.MetricsPort
+	}
+	return int32(p)
+}
+
 func newDeployment(opts options) runtime.Object {
 	d := apps.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -349,11 +419,55 @@ func newDeployment(opts options) runtime.Object {
 		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, vol)
 	}
 
+	if opts.LDAP.ConfigFile != "" {
+		volMount := core.VolumeMount{
+			Name:      "guard-ldap-config",
+			MountPath: filepath.Dir(ldap.LDAPConfigMountPath),
+		}
+		d.Spec.Template.Spec.Containers[0].VolumeMounts = append(d.Spec.Template.Spec.Containers[0].VolumeMounts, volMount)
+
+		vol := core.Volume{
+			Name: "guard-ldap-config",
+			VolumeSource: core.VolumeSource{
+				Secret: &core.SecretVolumeSource{
+					SecretName:  "guard-ldap-config",
+					DefaultMode: types.Int32P(0444),
+				},
+			},
+		}
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, vol)
+	}
+
+	if opts.Metrics.MetricsAddr != "" {
+		d.Spec.Template.Spec.Containers[0].Ports = append(d.Spec.Template.Spec.Containers[0].Ports, core.ContainerPort{
+			Name:          "metrics",
+			ContainerPort: metricsContainerPort(opts.Metrics.MetricsAddr),
+		})
+	}
+
+	if opts.Metrics.AuditLogPath != "" {
+		volMount := core.VolumeMount{
+			Name:      "guard-audit-log",
+			MountPath: filepath.Dir(server.AuditLogMountPath),
+		}
+		d.Spec.Template.Spec.Containers[0].VolumeMounts = append(d.Spec.Template.Spec.Containers[0].VolumeMounts, volMount)
+
+		vol := core.Volume{
+			Name: "guard-audit-log",
+			VolumeSource: core.VolumeSource{
+				EmptyDir: &core.EmptyDirVolumeSource{},
+			},
+		}
+		d.Spec.Template.Spec.Volumes = append(d.Spec.Template.Spec.Volumes, vol)
+	}
+
 	d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, server.SecureServingOptions{}.ToArgs()...)
 	d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, opts.Token.ToArgs()...)
 	d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, opts.Google.ToArgs()...)
 	d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, opts.Azure.ToArgs()...)
 	d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, opts.LDAP.ToArgs()...)
+	d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, opts.CertRotation.ToArgs()...)
+	d.Spec.Template.Spec.Containers[0].Args = append(d.Spec.Template.Spec.Containers[0].Args, opts.Metrics.ToArgs()...)
 
 	return &d
 }
@@ -410,6 +524,55 @@ func newClusterRole(namespace string) runtime.Object {
 	}
 }
 
+// newRole grants guard permission to get/update its own guard-pki Secret and
+// CA-bundle ConfigMap, needed by the certrotation controller, plus the
+// ConfigMap-based lease it uses for leader election across replicas.
+func newRole(namespace string, opts certrotation.Options) runtime.Object {
+	return &rbac.Role{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "guard-pki-rotation",
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Rules: []rbac.PolicyRule{
+			{
+				APIGroups:     []string{core.GroupName},
+				Resources:     []string{"secrets"},
+				ResourceNames: []string{opts.SecretName},
+				Verbs:         []string{"get", "update", "create"},
+			},
+			{
+				APIGroups:     []string{core.GroupName},
+				Resources:     []string{"configmaps"},
+				ResourceNames: []string{opts.CABundleConfigMapName, "guard-pki-rotation"},
+				Verbs:         []string{"get", "update", "create"},
+			},
+		},
+	}
+}
+
+func newRoleBinding(namespace string) runtime.Object {
+	return &rbac.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "guard-pki-rotation",
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		RoleRef: rbac.RoleRef{
+			APIGroup: rbac.GroupName,
+			Kind:     "Role",
+			Name:     "guard-pki-rotation",
+		},
+		Subjects: []rbac.Subject{
+			{
+				Kind:      rbac.ServiceAccountKind,
+				Name:      "guard",
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
 func newClusterRoleBinding(namespace string) runtime.Object {
 	return &rbac.ClusterRoleBinding{
 		ObjectMeta: metav1.ObjectMeta{
@@ -453,3 +616,14 @@ func newSecretForLDAPCert(namespace string, data map[string][]byte) runtime.Obje
 		Data: data,
 	}
 }
+
+func newSecretForLDAPConfig(namespace string, data map[string][]byte) runtime.Object {
+	return &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "guard-ldap-config",
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Data: data,
+	}
+}
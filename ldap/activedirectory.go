@@ -0,0 +1,144 @@
+package ldap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strings"
+
+	"github.com/go-ldap/ldap"
+)
+
+// DefaultADUserSearchFilter matches AD user objects. It is used in place of
+// UserSearchFilter when SearchMode is SearchModeActiveDirectory: AD user
+// objects are objectClass=user, not the objectClass=person/inetOrgPerson
+// shape UserSearchFilter defaults to for direct/filter mode.
+const DefaultADUserSearchFilter = "(objectClass=user)"
+
+// DefaultADGroupSearchFilter matches AD group objects. It is used in place
+// of GroupSearchFilter when SearchMode is SearchModeActiveDirectory: AD
+// group objects are objectClass=group, not the objectClass=groupOfNames
+// shape GroupSearchFilter defaults to for direct/filter mode. Without this,
+// activedirectory mode would silently resolve zero groups against a stock
+// AD schema unless the operator separately overrode
+// --ldap.group-search-filter.
+const DefaultADGroupSearchFilter = "(objectClass=group)"
+
+// adGroupMatchingRule is the AD "LDAP_MATCHING_RULE_IN_CHAIN" OID. Using it
+// in a (member:<rule>:=<userDN>) filter walks nested group membership in a
+// single query instead of requiring the caller to recurse manually.
+const adGroupMatchingRule = "1.2.840.113556.1.4.1941"
+
+// adUserAttributes are the attributes the AD user search requests: both
+// login name forms plus objectGUID/objectSid, so the resolved identity can
+// carry stable identity claims.
+var adUserAttributes = []string{"sAMAccountName", "userPrincipalName", "objectGUID", "objectSid"}
+
+// qualifiedUserPrincipalName returns username unchanged if it already looks
+// like a UPN (contains "@") or o.ADDomain is empty, otherwise qualifies it
+// with o.ADDomain.
+func (o *Options) qualifiedUserPrincipalName(username string) string {
+	if strings.Contains(username, "@") || o.ADDomain == "" {
+		return username
+	}
+	return fmt.Sprintf("%s@%s", username, o.ADDomain)
+}
+
+// newADUserSearchRequest builds a request that matches a user by either
+// sAMAccountName or userPrincipalName, since AD clients may authenticate
+// with either form.
+func (o *Options) newADUserSearchRequest(username string) *ldap.SearchRequest {
+	upn := o.qualifiedUserPrincipalName(username)
+	userFilter := fmt.Sprintf("(&%s(|(sAMAccountName=%s)(userPrincipalName=%s)))", DefaultADUserSearchFilter, username, upn)
+	return &ldap.SearchRequest{
+		BaseDN:       o.UserSearchDN,
+		Scope:        ldap.ScopeWholeSubtree,
+		DerefAliases: ldap.NeverDerefAliases,
+		SizeLimit:    2,
+		TimeLimit:    10,
+		TypesOnly:    false,
+		Filter:       userFilter,
+		Attributes:   adUserAttributes,
+	}
+}
+
+// newADGroupSearchRequest resolves nested group membership for userDN in a
+// single query using the AD matching-rule-in-chain extensible match, rather
+// than walking memberOf/member links by hand.
+func (o *Options) newADGroupSearchRequest(userDN string) *ldap.SearchRequest {
+	groupFilter := fmt.Sprintf("(&%s(%s:%s:=%s))", DefaultADGroupSearchFilter, o.GroupMemberAttribute, adGroupMatchingRule, userDN)
+	nameAttr := o.GroupNameAttribute
+	if o.ADGroupNameIsSAMAccountName {
+		nameAttr = "sAMAccountName"
+	}
+	return &ldap.SearchRequest{
+		BaseDN:       o.GroupSearchDN,
+		Scope:        ldap.ScopeWholeSubtree,
+		DerefAliases: ldap.NeverDerefAliases,
+		SizeLimit:    0,
+		TimeLimit:    10,
+		TypesOnly:    false,
+		Filter:       groupFilter,
+		Attributes:   []string{nameAttr},
+	}
+}
+
+// adGroupName returns the attribute value to use as the group's display
+// name, honoring ADGroupNameIsSAMAccountName.
+func (o *Options) adGroupName(entry *ldap.Entry) string {
+	if o.ADGroupNameIsSAMAccountName {
+		return entry.GetAttributeValue("sAMAccountName")
+	}
+	return entry.GetAttributeValue(o.GroupNameAttribute)
+}
+
+// objectGUIDString renders the raw little-endian objectGUID attribute value
+// in the canonical hyphenated GUID form used elsewhere in Windows tooling.
+func objectGUIDString(raw []byte) (string, error) {
+	if len(raw) != 16 {
+		return "", fmt.Errorf("objectGUID must be 16 bytes, got %d", len(raw))
+	}
+	return fmt.Sprintf("%08x-%04x-%04x-%04x-%012x",
+		binary.LittleEndian.Uint32(raw[0:4]),
+		binary.LittleEndian.Uint16(raw[4:6]),
+		binary.LittleEndian.Uint16(raw[6:8]),
+		raw[8:10],
+		raw[10:16]), nil
+}
+
+// objectSIDString renders the raw binary objectSid attribute value in its
+// "S-1-..." string form.
+func objectSIDString(raw []byte) (string, error) {
+	if len(raw) < 8 {
+		return "", fmt.Errorf("objectSid too short: %d bytes", len(raw))
+	}
+	revision := raw[0]
+	subAuthorityCount := int(raw[1])
+	authority := uint64(0)
+	for i := 2; i < 8; i++ {
+		authority = authority<<8 | uint64(raw[i])
+	}
+	sid := fmt.Sprintf("S-%d-%d", revision, authority)
+	offset := 8
+	for i := 0; i < subAuthorityCount && offset+4 <= len(raw); i++ {
+		sid += fmt.Sprintf("-%d", binary.LittleEndian.Uint32(raw[offset:offset+4]))
+		offset += 4
+	}
+	return sid, nil
+}
+
+// adIdentityClaims extracts the stable objectGUID/objectSid identity claims
+// for a resolved AD user entry, to be surfaced alongside the username and
+// group claims.
+func adIdentityClaims(entry *ldap.Entry) (guid, sid string, err error) {
+	if raw := entry.GetRawAttributeValue("objectGUID"); len(raw) > 0 {
+		if guid, err = objectGUIDString(raw); err != nil {
+			return "", "", err
+		}
+	}
+	if raw := entry.GetRawAttributeValue("objectSid"); len(raw) > 0 {
+		if sid, err = objectSIDString(raw); err != nil {
+			return "", "", err
+		}
+	}
+	return guid, sid, nil
+}
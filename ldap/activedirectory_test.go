@@ -0,0 +1,90 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestObjectGUIDString(t *testing.T) {
+	// Little-endian encoding of 01020304-0506-0708-090a-0b0c0d0e0f10
+	raw := []byte{
+		0x04, 0x03, 0x02, 0x01,
+		0x06, 0x05,
+		0x08, 0x07,
+		0x09, 0x0a,
+		0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10,
+	}
+	got, err := objectGUIDString(raw)
+	if err != nil {
+		t.Fatalf("objectGUIDString: %v", err)
+	}
+	want := "01020304-0506-0708-090a-0b0c0d0e0f10"
+	if got != want {
+		t.Errorf("objectGUIDString = %q, want %q", got, want)
+	}
+}
+
+func TestObjectGUIDStringWrongLength(t *testing.T) {
+	if _, err := objectGUIDString([]byte{1, 2, 3}); err == nil {
+		t.Fatalf("expected an error for a short objectGUID")
+	}
+}
+
+func TestObjectSIDString(t *testing.T) {
+	// S-1-5-21-3623811015-3361044348-30300820-1013
+	raw := []byte{
+		0x01,       // revision
+		0x05,       // sub-authority count
+		0, 0, 0, 0, 0, 5, // identifier authority (5)
+	}
+	subAuthorities := []uint32{21, 3623811015, 3361044348, 30300820, 1013}
+	for _, sa := range subAuthorities {
+		raw = append(raw,
+			byte(sa), byte(sa>>8), byte(sa>>16), byte(sa>>24),
+		)
+	}
+
+	got, err := objectSIDString(raw)
+	if err != nil {
+		t.Fatalf("objectSIDString: %v", err)
+	}
+	want := "S-1-5-21-3623811015-3361044348-30300820-1013"
+	if got != want {
+		t.Errorf("objectSIDString = %q, want %q", got, want)
+	}
+}
+
+func TestObjectSIDStringTooShort(t *testing.T) {
+	if _, err := objectSIDString([]byte{1, 2}); err == nil {
+		t.Fatalf("expected an error for a too-short objectSid")
+	}
+}
+
+func TestNewADUserSearchRequestUsesADFilter(t *testing.T) {
+	o := &Options{
+		SearchMode:       SearchModeActiveDirectory,
+		UserSearchFilter: "(objectClass=person)", // direct-mode default; must not leak into AD mode
+	}
+	req := o.newADUserSearchRequest("alice")
+	if !strings.Contains(req.Filter, "objectClass=user") {
+		t.Errorf("expected AD user filter to require objectClass=user, got %q", req.Filter)
+	}
+	if strings.Contains(req.Filter, "objectClass=person") {
+		t.Errorf("AD user filter must not use the direct-mode objectClass=person filter, got %q", req.Filter)
+	}
+}
+
+func TestNewADGroupSearchRequestUsesADFilter(t *testing.T) {
+	o := &Options{
+		SearchMode:           SearchModeActiveDirectory,
+		GroupSearchFilter:    "(objectClass=groupOfNames)", // direct-mode default; must not leak into AD mode
+		GroupMemberAttribute: "member",
+	}
+	req := o.newADGroupSearchRequest("cn=alice,dc=example,dc=com")
+	if !strings.Contains(req.Filter, "objectClass=group") {
+		t.Errorf("expected AD group filter to require objectClass=group, got %q", req.Filter)
+	}
+	if strings.Contains(req.Filter, "groupOfNames") {
+		t.Errorf("AD group filter must not use the direct-mode groupOfNames filter, got %q", req.Filter)
+	}
+}
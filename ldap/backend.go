@@ -0,0 +1,23 @@
+package ldap
+
+// Backend holds the LDAP connection and search configuration for a single
+// LDAP tree. It embeds Options so a Backend supports exactly the same bind,
+// search and validation behavior as the single-tenant configuration; a
+// Registry simply picks which Backend's Options to use for a given request.
+type Backend struct {
+	Options
+
+	// BaseDNs lists the search base DN (or DN suffixes) that route to this
+	// backend. A username-qualified DN is routed to the backend whose
+	// BaseDNs contains the longest matching suffix.
+	BaseDNs []string `json:"baseDNs"`
+
+	// Default marks this as the catch-all backend used for a request that
+	// carries no DN to route on (e.g. a bare username, as from a kubectl
+	// bearer token). At most one backend in a Config may set this. If none
+	// does, Registry falls back to the backend with the broadest (shortest)
+	// BaseDN, since the most specific backend silently becoming the
+	// catch-all for every unqualified username is almost never what's
+	// intended.
+	Default bool `json:"default,omitempty"`
+}
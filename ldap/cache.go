@@ -0,0 +1,83 @@
+package ldap
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// lookupResult is what Client caches per username: the resolved DN and its
+// group membership, good until expiresAt.
+type lookupResult struct {
+	dn        string
+	groups    []string
+	expiresAt time.Time
+}
+
+// lookupCache is a small in-memory LRU of username -> lookupResult, used to
+// avoid re-querying the directory for chatty kubectl clients that re-auth on
+// every request. It is safe for concurrent use.
+type lookupCache struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxItems int
+	items    map[string]*list.Element
+	order    *list.List
+}
+
+type cacheEntry struct {
+	username string
+	result   lookupResult
+}
+
+// newLookupCache creates a cache with the given TTL. maxItems bounds memory
+// use; the least recently used entry is evicted once it is exceeded.
+func newLookupCache(ttl time.Duration, maxItems int) *lookupCache {
+	return &lookupCache{
+		ttl:      ttl,
+		maxItems: maxItems,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *lookupCache) get(username string) (lookupResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.items[username]
+	if !ok {
+		return lookupResult{}, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if time.Now().After(entry.result.expiresAt) {
+		c.order.Remove(el)
+		delete(c.items, username)
+		return lookupResult{}, false
+	}
+	c.order.MoveToFront(el)
+	return entry.result, true
+}
+
+func (c *lookupCache) set(username string, dn string, groups []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	result := lookupResult{dn: dn, groups: groups, expiresAt: time.Now().Add(c.ttl)}
+	if el, ok := c.items[username]; ok {
+		el.Value.(*cacheEntry).result = result
+		c.order.MoveToFront(el)
+		return
+	}
+
+	el := c.order.PushFront(&cacheEntry{username: username, result: result})
+	c.items[username] = el
+
+	if c.maxItems > 0 && c.order.Len() > c.maxItems {
+		oldest := c.order.Back()
+		if oldest != nil {
+			c.order.Remove(oldest)
+			delete(c.items, oldest.Value.(*cacheEntry).username)
+		}
+	}
+}
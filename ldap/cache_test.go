@@ -0,0 +1,77 @@
+package ldap
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookupCacheSetGet(t *testing.T) {
+	c := newLookupCache(time.Minute, 10)
+
+	if _, ok := c.get("alice"); ok {
+		t.Fatalf("expected miss on empty cache")
+	}
+
+	c.set("alice", "cn=alice,dc=example,dc=com", []string{"dev", "ops"})
+
+	result, ok := c.get("alice")
+	if !ok {
+		t.Fatalf("expected hit after set")
+	}
+	if result.dn != "cn=alice,dc=example,dc=com" {
+		t.Errorf("dn = %q, want cn=alice,dc=example,dc=com", result.dn)
+	}
+	if len(result.groups) != 2 || result.groups[0] != "dev" || result.groups[1] != "ops" {
+		t.Errorf("groups = %v, want [dev ops]", result.groups)
+	}
+}
+
+func TestLookupCacheExpiry(t *testing.T) {
+	c := newLookupCache(time.Millisecond, 10)
+	c.set("alice", "cn=alice,dc=example,dc=com", []string{"dev"})
+
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok := c.get("alice"); ok {
+		t.Fatalf("expected entry to have expired")
+	}
+}
+
+func TestLookupCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	c := newLookupCache(time.Minute, 2)
+
+	c.set("alice", "dn-alice", nil)
+	c.set("bob", "dn-bob", nil)
+
+	// Touch alice so bob becomes the least recently used entry.
+	c.get("alice")
+
+	c.set("carol", "dn-carol", nil)
+
+	if _, ok := c.get("bob"); ok {
+		t.Fatalf("expected bob to be evicted")
+	}
+	if _, ok := c.get("alice"); !ok {
+		t.Fatalf("expected alice to still be cached")
+	}
+	if _, ok := c.get("carol"); !ok {
+		t.Fatalf("expected carol to be cached")
+	}
+}
+
+func TestLookupCacheSetOverwritesExisting(t *testing.T) {
+	c := newLookupCache(time.Minute, 10)
+	c.set("alice", "dn-v1", []string{"dev"})
+	c.set("alice", "dn-v2", []string{"dev", "ops"})
+
+	result, ok := c.get("alice")
+	if !ok {
+		t.Fatalf("expected hit")
+	}
+	if result.dn != "dn-v2" {
+		t.Errorf("dn = %q, want dn-v2", result.dn)
+	}
+	if len(result.groups) != 2 {
+		t.Errorf("groups = %v, want 2 entries", result.groups)
+	}
+}
@@ -0,0 +1,299 @@
+package ldap
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+
+	"github.com/go-ldap/ldap"
+	"golang.org/x/sync/errgroup"
+)
+
+// Client is a pooled LDAP client: it keeps MaxConnections long-lived bound
+// connections open to the server instead of dialing and binding on every
+// user/group lookup, and reconnects a connection transparently when it sees
+// ldap.ErrorNetwork. A resolved user DN is additionally cached in memory to
+// save the user search round trip for clients (notably kubectl) that
+// re-authenticate on every request; credentials are still validated and
+// group membership is still searched on every call.
+type Client struct {
+	opts *Options
+	pool chan *ldap.Conn
+	size int
+
+	cache *lookupCache // nil when Options.CacheTTL == 0
+}
+
+// NewClient dials and binds Options.MaxConnections connections up front and
+// returns a Client backed by that pool.
+func NewClient(o *Options) (*Client, error) {
+	size := o.MaxConnections
+	if size <= 0 {
+		size = DefaultMaxConnections
+	}
+
+	c := &Client{
+		opts: o,
+		pool: make(chan *ldap.Conn, size),
+		size: size,
+	}
+	if o.CacheTTL > 0 {
+		c.cache = newLookupCache(o.CacheTTL, size*100)
+	}
+
+	for i := 0; i < size; i++ {
+		conn, err := c.dialAndBind(o.BindDN, o.BindPassword)
+		if err != nil {
+			c.Close()
+			return nil, fmt.Errorf("ldap: failed to establish connection pool: %v", err)
+		}
+		c.pool <- conn
+	}
+	return c, nil
+}
+
+// Close tears down every pooled connection.
+func (c *Client) Close() {
+	for {
+		select {
+		case conn := <-c.pool:
+			conn.Close()
+		default:
+			return
+		}
+	}
+}
+
+func (c *Client) dialAndBind(bindDN, bindPassword string) (*ldap.Conn, error) {
+	addr := net.JoinHostPort(c.opts.ServerAddress, c.opts.ServerPort)
+
+	var conn *ldap.Conn
+	var err error
+	if c.opts.IsSecureLDAP {
+		conn, err = ldap.DialTLS("tcp", addr, &tls.Config{
+			ServerName:         c.opts.ServerAddress,
+			InsecureSkipVerify: c.opts.SkipTLSVerification,
+			RootCAs:            c.opts.CaCertPool,
+		})
+	} else {
+		conn, err = ldap.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(c.opts.ConnectionTimeout)
+
+	if c.opts.StartTLS {
+		if err := conn.StartTLS(&tls.Config{
+			ServerName:         c.opts.ServerAddress,
+			InsecureSkipVerify: c.opts.SkipTLSVerification,
+			RootCAs:            c.opts.CaCertPool,
+		}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if bindDN != "" {
+		if err := conn.Bind(bindDN, bindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// acquire takes a connection from the pool, reconnecting it first if it was
+// returned unhealthy by a previous caller.
+func (c *Client) acquire() (*ldap.Conn, error) {
+	conn := <-c.pool
+	if conn == nil {
+		conn, err := c.dialAndBind(c.opts.BindDN, c.opts.BindPassword)
+		if err != nil {
+			// Put the placeholder back so the slot isn't lost.
+			c.pool <- nil
+			return nil, err
+		}
+		return conn, nil
+	}
+	return conn, nil
+}
+
+// release returns conn to the pool, or reconnects and returns a fresh
+// connection in its place if healthy is false (set by the caller after
+// seeing an ldap.ErrorNetwork result code).
+func (c *Client) release(conn *ldap.Conn, healthy bool) {
+	if healthy {
+		c.pool <- conn
+		return
+	}
+	conn.Close()
+	newConn, err := c.dialAndBind(c.opts.BindDN, c.opts.BindPassword)
+	if err != nil {
+		// Leave a nil placeholder; the next acquire() will retry dialing
+		// rather than blocking forever on a pool slot that lost its
+		// connection.
+		c.pool <- nil
+		return
+	}
+	c.pool <- newConn
+}
+
+// isNetworkError reports whether err is an ldap.ErrorNetwork, in which case
+// the connection that produced it must not be returned to the pool as-is.
+func isNetworkError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if le, ok := err.(*ldap.Error); ok {
+		return le.ResultCode == ldap.ErrorNetwork
+	}
+	return false
+}
+
+// search runs req against a pooled connection, transparently reconnecting
+// and retrying once on a network error.
+func (c *Client) search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	conn, err := c.acquire()
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(c.opts.RequestTimeout)
+	result, err := conn.Search(req)
+	c.release(conn, !isNetworkError(err))
+	if isNetworkError(err) {
+		// Retry once against a freshly reconnected pool member.
+		conn, err = c.acquire()
+		if err != nil {
+			return nil, err
+		}
+		conn.SetTimeout(c.opts.RequestTimeout)
+		result, err = conn.Search(req)
+		c.release(conn, !isNetworkError(err))
+	}
+	return result, err
+}
+
+// userDN resolves username to its DN using a pooled connection.
+func (c *Client) userDN(username string) (string, error) {
+	result, err := c.search(c.opts.newUserSearchRequest(username))
+	if err != nil {
+		return "", fmt.Errorf("ldap: user search for %q failed: %v", username, err)
+	}
+	if len(result.Entries) == 0 {
+		return "", fmt.Errorf("ldap: no user found for %q", username)
+	}
+	if len(result.Entries) > 1 {
+		return "", fmt.Errorf("ldap: multiple users found for %q", username)
+	}
+	return result.Entries[0].DN, nil
+}
+
+// groupNames resolves the group membership for userDN using a pooled
+// connection.
+func (c *Client) groupNames(userDN string) ([]string, error) {
+	result, err := c.search(c.opts.newGroupSearchRequest(userDN))
+	if err != nil {
+		return nil, fmt.Errorf("ldap: group search for %q failed: %v", userDN, err)
+	}
+	groups := make([]string, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		if c.opts.SearchMode == SearchModeActiveDirectory {
+			groups = append(groups, c.opts.adGroupName(entry))
+			continue
+		}
+		groups = append(groups, entry.GetAttributeValue(c.opts.GroupNameAttribute))
+	}
+	return groups, nil
+}
+
+// validateCredentials confirms password by binding as userDN on a throwaway
+// connection; it does not touch the pool since the pool's connections are
+// bound as the service account, not as individual users.
+//
+// An empty password must never reach conn.Bind: most LDAP servers treat a
+// simple bind with a non-empty DN and an empty password as an
+// unauthenticated bind (RFC 4513 §5.1.2) and report success without
+// checking the password at all.
+func (c *Client) validateCredentials(userDN, password string) error {
+	if password == "" {
+		return fmt.Errorf("ldap: invalid credentials: empty password")
+	}
+	conn, err := c.dialAndBind(userDN, password)
+	if err != nil {
+		return fmt.Errorf("ldap: invalid credentials: %v", err)
+	}
+	conn.Close()
+	return nil
+}
+
+// Authenticate verifies username/password against the directory and returns
+// the user's DN and resolved group membership. When a cached DN is
+// available (from a previous successful Authenticate call within
+// Options.CacheTTL), credential validation and the group search are issued
+// concurrently since neither depends on the other once the DN is known.
+func (c *Client) Authenticate(username, password string) (dn string, groups []string, err error) {
+	if password == "" {
+		return "", nil, fmt.Errorf("ldap: invalid credentials: empty password")
+	}
+	if c.cache != nil {
+		if cached, ok := c.cache.get(username); ok {
+			groups, err := c.validateAndRefreshGroups(cached.dn, password, username)
+			if err != nil {
+				return "", nil, err
+			}
+			return cached.dn, groups, nil
+		}
+	}
+
+	dn, err = c.userDN(username)
+	if err != nil {
+		return "", nil, err
+	}
+
+	g, _ := errgroup.WithContext(context.Background())
+	var resolvedGroups []string
+	g.Go(func() error {
+		return c.validateCredentials(dn, password)
+	})
+	g.Go(func() error {
+		var err error
+		resolvedGroups, err = c.groupNames(dn)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return "", nil, err
+	}
+
+	if c.cache != nil {
+		c.cache.set(username, dn, resolvedGroups)
+	}
+	return dn, resolvedGroups, nil
+}
+
+// validateAndRefreshGroups runs credential validation and a group-membership
+// refresh concurrently for an already-known DN, re-populates the cache with
+// the refreshed group list on success, and returns that group list directly
+// — callers must use the returned value rather than re-reading the cache,
+// since the entry they just set() can be evicted or expire before they get
+// a chance to get() it back.
+func (c *Client) validateAndRefreshGroups(dn, password, username string) ([]string, error) {
+	g, _ := errgroup.WithContext(context.Background())
+	var groups []string
+	g.Go(func() error {
+		return c.validateCredentials(dn, password)
+	})
+	g.Go(func() error {
+		var err error
+		groups, err = c.groupNames(dn)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	if c.cache != nil {
+		c.cache.set(username, dn, groups)
+	}
+	return groups, nil
+}
@@ -0,0 +1,42 @@
+package ldap
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-ldap/ldap"
+)
+
+func TestIsNetworkError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"non-ldap error", errors.New("boom"), false},
+		{"ldap network error", ldap.NewError(ldap.ErrorNetwork, errors.New("conn reset")), true},
+		{"other ldap error", ldap.NewError(ldap.LDAPResultNoSuchObject, errors.New("not found")), false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isNetworkError(tc.err); got != tc.want {
+				t.Errorf("isNetworkError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateCredentialsRejectsEmptyPassword(t *testing.T) {
+	c := &Client{opts: &Options{}}
+	if err := c.validateCredentials("cn=alice,dc=example,dc=com", ""); err == nil {
+		t.Fatalf("expected empty password to be rejected")
+	}
+}
+
+func TestAuthenticateRejectsEmptyPassword(t *testing.T) {
+	c := &Client{opts: &Options{}}
+	if _, _, err := c.Authenticate("alice", ""); err == nil {
+		t.Fatalf("expected empty password to be rejected")
+	}
+}
@@ -0,0 +1,49 @@
+package ldap
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Config is the on-disk schema for --ldap.config-file: a list of backends,
+// each routed by one or more base DNs. It lets a single guard server front
+// several LDAP trees instead of the single flat Options pair.
+type Config struct {
+	Backends []Backend `json:"backends"`
+}
+
+// LoadConfigFile reads and parses a --ldap.config-file.
+func LoadConfigFile(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ldap config file %s: %v", path, err)
+	}
+	var cfg Config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse ldap config file %s: %v", path, err)
+	}
+	defaults := 0
+	for i := range cfg.Backends {
+		if len(cfg.Backends[i].BaseDNs) == 0 {
+			return nil, fmt.Errorf("ldap config file %s: backend %d has no baseDNs", path, i)
+		}
+		if cfg.Backends[i].Default {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return nil, fmt.Errorf("ldap config file %s: at most one backend may set default: true, got %d", path, defaults)
+	}
+	return &cfg, nil
+}
+
+// NewRegistry builds a Registry from the Config's backends.
+func (c *Config) NewRegistry() *Registry {
+	backends := make([]*Backend, len(c.Backends))
+	for i := range c.Backends {
+		backends[i] = &c.Backends[i]
+	}
+	return NewRegistry(backends...)
+}
@@ -0,0 +1,52 @@
+package ldap
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeTempConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	f, err := ioutil.TempFile("", "guard-ldap-config-*.yaml")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(yaml); err != nil {
+		t.Fatalf("WriteString: %v", err)
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+	return f.Name()
+}
+
+func TestLoadConfigFileRejectsMultipleDefaults(t *testing.T) {
+	path := writeTempConfig(t, `
+backends:
+- baseDNs: ["dc=example,dc=com"]
+  default: true
+- baseDNs: ["ou=eng,dc=example,dc=com"]
+  default: true
+`)
+	if _, err := LoadConfigFile(path); err == nil {
+		t.Fatalf("expected an error for more than one default backend")
+	}
+}
+
+func TestLoadConfigFileAcceptsSingleDefault(t *testing.T) {
+	path := writeTempConfig(t, `
+backends:
+- baseDNs: ["dc=example,dc=com"]
+  default: true
+- baseDNs: ["ou=eng,dc=example,dc=com"]
+`)
+	cfg, err := LoadConfigFile(path)
+	if err != nil {
+		t.Fatalf("LoadConfigFile: %v", err)
+	}
+	registry := cfg.NewRegistry()
+	def, ok := registry.Default()
+	if !ok || len(def.BaseDNs) != 1 || def.BaseDNs[0] != "dc=example,dc=com" {
+		t.Fatalf("expected the explicitly marked backend as default, got %+v", def)
+	}
+}
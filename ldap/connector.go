@@ -0,0 +1,107 @@
+package ldap
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Connector is the LDAP auth flow entry point: it resolves which Backend a
+// request belongs to (single default backend, or one of several loaded from
+// Options.ConfigFile) and dispatches the user/group search there through
+// that backend's pooled Client.
+type Connector struct {
+	registry *Registry
+
+	mu      sync.Mutex
+	clients map[*Backend]*Client
+}
+
+// NewConnector builds a Connector from o. When o.ConfigFile is set, it loads
+// the multi-tenant backend configuration and routes by base DN; otherwise it
+// falls back to a single default backend built from the rest of o, so
+// existing single-tenant deployments keep working unchanged.
+func NewConnector(o *Options) (*Connector, error) {
+	registry, err := registryFromOptions(o)
+	if err != nil {
+		return nil, err
+	}
+	return &Connector{
+		registry: registry,
+		clients:  make(map[*Backend]*Client),
+	}, nil
+}
+
+func registryFromOptions(o *Options) (*Registry, error) {
+	if o.ConfigFile != "" {
+		cfg, err := LoadConfigFile(o.ConfigFile)
+		if err != nil {
+			return nil, err
+		}
+		return cfg.NewRegistry(), nil
+	}
+	return NewRegistry(&Backend{
+		Options: *o,
+		BaseDNs: []string{o.UserSearchDN, o.GroupSearchDN},
+	}), nil
+}
+
+// backendFor picks the Backend a request routes to and the bare username to
+// search for within it. A username may be qualified with the base DN to
+// route on, "<username>@<baseDN>" (e.g. "alice@ou=eng,dc=example,dc=com"),
+// mirroring the UPN-style qualification already used for Active Directory
+// usernames. An unqualified username falls back to the registry's default
+// backend, preserving single-tenant behavior.
+func (c *Connector) backendFor(username string) (*Backend, string) {
+	if idx := strings.LastIndex(username, "@"); idx >= 0 {
+		local, routingDN := username[:idx], username[idx+1:]
+		if strings.Contains(routingDN, "=") {
+			if b, ok := c.registry.Lookup(routingDN); ok {
+				return b, local
+			}
+		}
+	}
+	if b, ok := c.registry.Default(); ok {
+		return b, username
+	}
+	return nil, username
+}
+
+// clientFor lazily dials and caches the pooled Client for a backend.
+func (c *Connector) clientFor(b *Backend) (*Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[b]; ok {
+		return client, nil
+	}
+	client, err := NewClient(&b.Options)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[b] = client
+	return client, nil
+}
+
+// Authenticate routes username to its Backend (see backendFor) and
+// authenticates against it, returning the resolved DN and group membership.
+func (c *Connector) Authenticate(username, password string) (dn string, groups []string, err error) {
+	backend, routedUsername := c.backendFor(username)
+	if backend == nil {
+		return "", nil, fmt.Errorf("ldap: no backend configured for %q", username)
+	}
+	client, err := c.clientFor(backend)
+	if err != nil {
+		return "", nil, err
+	}
+	return client.Authenticate(routedUsername, password)
+}
+
+// Close tears down every backend's connection pool.
+func (c *Connector) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, client := range c.clients {
+		client.Close()
+	}
+}
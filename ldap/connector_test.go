@@ -0,0 +1,54 @@
+package ldap
+
+import "testing"
+
+func TestConnectorBackendForRoutesByBaseDN(t *testing.T) {
+	eng := &Backend{BaseDNs: []string{"ou=eng,dc=example,dc=com"}}
+	sales := &Backend{BaseDNs: []string{"ou=sales,dc=example,dc=com"}}
+	c := &Connector{registry: NewRegistry(eng, sales)}
+
+	backend, username := c.backendFor("alice@ou=eng,dc=example,dc=com")
+	if backend != eng {
+		t.Fatalf("expected routing to the eng backend")
+	}
+	if username != "alice" {
+		t.Fatalf("username = %q, want alice", username)
+	}
+
+	backend, username = c.backendFor("bob@ou=sales,dc=example,dc=com")
+	if backend != sales {
+		t.Fatalf("expected routing to the sales backend")
+	}
+	if username != "bob" {
+		t.Fatalf("username = %q, want bob", username)
+	}
+}
+
+func TestConnectorBackendForFallsBackToDefault(t *testing.T) {
+	only := &Backend{BaseDNs: []string{"dc=example,dc=com"}}
+	c := &Connector{registry: NewRegistry(only)}
+
+	backend, username := c.backendFor("alice")
+	if backend != only {
+		t.Fatalf("expected an unqualified username to route to the default backend")
+	}
+	if username != "alice" {
+		t.Fatalf("username = %q, want alice", username)
+	}
+}
+
+func TestConnectorBackendForUnroutableEmailLocalPart(t *testing.T) {
+	only := &Backend{BaseDNs: []string{"dc=example,dc=com"}}
+	c := &Connector{registry: NewRegistry(only)}
+
+	// "@corp.example.com" has no "=" in it, so it isn't mistaken for a
+	// routing DN; the whole string is treated as an unqualified username
+	// and falls back to the default backend.
+	backend, username := c.backendFor("alice@corp.example.com")
+	if backend != only {
+		t.Fatalf("expected fallback to the default backend")
+	}
+	if username != "alice@corp.example.com" {
+		t.Fatalf("username = %q, want the string unchanged", username)
+	}
+}
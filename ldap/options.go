@@ -3,21 +3,48 @@ package ldap
 import (
 	"crypto/x509"
 	"fmt"
+	"strings"
+	"time"
 
 	"github.com/go-ldap/ldap"
 	"github.com/spf13/pflag"
 )
 
+// SearchMode selects the strategy used to resolve a user's DN and group
+// membership.
+type SearchMode string
+
+const (
+	// SearchModeDirect is the classic guard behaviour: the user is found
+	// with UserSearchFilter/UserAttribute and its groups are found with a
+	// single GroupSearchFilter/GroupMemberAttribute query rooted at
+	// GroupSearchDN.
+	SearchModeDirect SearchMode = "direct"
+	// SearchModeFilter is like SearchModeDirect for resolving the user, but
+	// GroupSearchFilter is used as the whole group search filter verbatim,
+	// with a single %s substituted for the user's DN, instead of being
+	// wrapped in the built-in (&GroupSearchFilter(GroupMemberAttribute=DN))
+	// template. This lets the operator search across multiple group object
+	// classes or match on something other than a plain member attribute
+	// (e.g. a memberUrl-based dynamic group filter).
+	SearchModeFilter SearchMode = "filter"
+	// SearchModeActiveDirectory authenticates users by sAMAccountName or
+	// userPrincipalName and resolves nested group membership transitively
+	// in a single query using the AD LDAP_MATCHING_RULE_IN_CHAIN rule.
+	SearchModeActiveDirectory SearchMode = "activedirectory"
+)
+
 type Options struct {
 	ServerAddress        string
 	ServerPort           string
 	BindDN               string // The connector uses this DN in credentials to search for users and groups. Not required if the LDAP server provides access for anonymous auth.
 	BindPassword         string // The connector uses this Password in credentials to search for users and groups. Not required if the LDAP server provides access for anonymous auth.
+	SearchMode           SearchMode
 	UserSearchDN         string // BaseDN to start the search user
 	UserSearchFilter     string // filter to apply when searching user, default : (objectClass=person)
 	UserAttribute        string // Ldap username attribute, default : uid
 	GroupSearchDN        string // BaseDN to start the search group
-	GroupSearchFilter    string // filter to apply when searching the groups that user is member of, default : (objectClass=groupOfNames)
+	GroupSearchFilter    string // filter to apply when searching the groups that user is member of, default : (objectClass=groupOfNames). When SearchMode is SearchModeFilter, this is used as the entire filter verbatim, with %s substituted for the user's DN.
 	GroupMemberAttribute string // Ldap group member attribute, default: member
 	GroupNameAttribute   string // Ldap group name attribute, default: cn
 	SkipTLSVerification  bool
@@ -25,6 +52,34 @@ type Options struct {
 	StartTLS             bool   // for start tls connection
 	CaCertFile           string // path to the caCert file, needed for self signed server certificate
 	CaCertPool           *x509.CertPool
+
+	// ADDomain is the Active Directory domain/UPN suffix (e.g. "example.com")
+	// appended to a bare username to build its userPrincipalName. Only used
+	// when SearchMode is SearchModeActiveDirectory.
+	ADDomain string
+	// ADGroupNameIsSAMAccountName surfaces resolved group names as
+	// sAMAccountName instead of GroupNameAttribute (cn) when set. Only used
+	// when SearchMode is SearchModeActiveDirectory.
+	ADGroupNameIsSAMAccountName bool
+
+	// ConfigFile, if set, points to a YAML file describing multiple LDAP
+	// backends keyed by base DN (see Config). When set, it takes precedence
+	// over the rest of Options and the flag-based fields above are not
+	// used; when unset, they work unchanged as the configuration for a
+	// single default backend.
+	ConfigFile string
+
+	// MaxConnections is the number of long-lived bound connections the
+	// pooled Client keeps open to the LDAP server.
+	MaxConnections int
+	// ConnectionTimeout is set on a connection as soon as it is dialed, so
+	// it bounds StartTLS and the bind; it does not bound the dial itself.
+	ConnectionTimeout time.Duration
+	// RequestTimeout bounds a single search request issued through the pool.
+	RequestTimeout time.Duration
+	// CacheTTL is how long a resolved username -> (dn, groups) lookup is
+	// cached in memory. Zero disables the cache.
+	CacheTTL time.Duration
 }
 
 func (o *Options) AddFlags(fs *pflag.FlagSet) {
@@ -32,6 +87,7 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&o.ServerPort, "ldap.server-port", "389", "LDAP server port")
 	fs.StringVar(&o.BindDN, "ldap.bind-dn", o.BindDN, "The connector uses this DN in credentials to search for users and groups. Not required if the LDAP server provides access for anonymous auth.")
 	fs.StringVar(&o.BindPassword, "ldap.bind-password", o.BindPassword, "The connector uses this password in credentials to search for users and groups. Not required if the LDAP server provides access for anonymous auth.")
+	fs.StringVar((*string)(&o.SearchMode), "ldap.search-mode", string(SearchModeDirect), "LDAP search mode to use for resolving users and groups: direct, filter, or activedirectory")
 	fs.StringVar(&o.UserSearchDN, "ldap.user-search-dn", o.UserSearchDN, "BaseDN to start the search user")
 	fs.StringVar(&o.UserSearchFilter, "ldap.user-search-filter", DefaultUserSearchFilter, "Filter to apply when searching user")
 	fs.StringVar(&o.UserAttribute, "ldap.user-attribute", DefaultUserAttribute, "Ldap username attribute")
@@ -43,8 +99,21 @@ func (o *Options) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&o.IsSecureLDAP, "ldap.is-secure-ldap", false, "Secure LDAP (LDAPS)")
 	fs.BoolVar(&o.StartTLS, "ldap.start-tls", false, "Start tls connection")
 	fs.StringVar(&o.CaCertFile, "ldap.ca-cert-file", "", "ca cert file that used for self signed server certificate")
+	fs.StringVar(&o.ADDomain, "ldap.ad-domain", o.ADDomain, "Active Directory domain/UPN suffix, used to qualify bare usernames when ldap.search-mode=activedirectory")
+	fs.BoolVar(&o.ADGroupNameIsSAMAccountName, "ldap.ad-group-name-is-samaccountname", false, "Surface resolved group names as sAMAccountName instead of ldap.group-name-attribute, when ldap.search-mode=activedirectory")
+	fs.StringVar(&o.ConfigFile, "ldap.config-file", o.ConfigFile, "Path to a YAML file describing multiple LDAP backends keyed by base DN, for multi-tenant routing. The flags above continue to define a single default backend when this is unset.")
+	fs.IntVar(&o.MaxConnections, "ldap.max-connections", DefaultMaxConnections, "Number of long-lived bound connections to keep open to the LDAP server")
+	fs.DurationVar(&o.ConnectionTimeout, "ldap.connection-timeout", DefaultConnectionTimeout, "Timeout for StartTLS and binding on a newly dialed LDAP connection (does not bound the dial itself)")
+	fs.DurationVar(&o.RequestTimeout, "ldap.request-timeout", DefaultRequestTimeout, "Timeout for a single LDAP search request issued through the connection pool")
+	fs.DurationVar(&o.CacheTTL, "ldap.cache-ttl", 0, "How long to cache a resolved username -> (dn, groups) lookup in memory. 0 disables the cache.")
 }
 
+const (
+	DefaultMaxConnections    = 10
+	DefaultConnectionTimeout = 5 * time.Second
+	DefaultRequestTimeout    = 10 * time.Second
+)
+
 func (o Options) ToArgs() []string {
 	var args []string
 	if o.ServerAddress != "" {
@@ -59,6 +128,9 @@ func (o Options) ToArgs() []string {
 	if o.BindPassword != "" {
 		args = append(args, fmt.Sprintf("--ldap.bind-password=%s", o.BindPassword))
 	}
+	if o.SearchMode != "" {
+		args = append(args, fmt.Sprintf("--ldap.search-mode=%s", o.SearchMode))
+	}
 	if o.UserSearchDN != "" {
 		args = append(args, fmt.Sprintf("--ldap.user-search-dn=%s", o.UserSearchDN))
 	}
@@ -92,11 +164,39 @@ func (o Options) ToArgs() []string {
 	if o.CaCertFile != "" {
 		args = append(args, fmt.Sprintf("--ldap.ca-cert-file=/etc/guard/certs/ca.crt"))
 	}
+	if o.ADDomain != "" {
+		args = append(args, fmt.Sprintf("--ldap.ad-domain=%s", o.ADDomain))
+	}
+	if o.ADGroupNameIsSAMAccountName {
+		args = append(args, "--ldap.ad-group-name-is-samaccountname")
+	}
+	if o.ConfigFile != "" {
+		args = append(args, fmt.Sprintf("--ldap.config-file=%s", LDAPConfigMountPath))
+	}
+	if o.MaxConnections != 0 {
+		args = append(args, fmt.Sprintf("--ldap.max-connections=%d", o.MaxConnections))
+	}
+	if o.ConnectionTimeout != 0 {
+		args = append(args, fmt.Sprintf("--ldap.connection-timeout=%s", o.ConnectionTimeout))
+	}
+	if o.RequestTimeout != 0 {
+		args = append(args, fmt.Sprintf("--ldap.request-timeout=%s", o.RequestTimeout))
+	}
+	if o.CacheTTL != 0 {
+		args = append(args, fmt.Sprintf("--ldap.cache-ttl=%s", o.CacheTTL))
+	}
 	return args
 }
 
+// LDAPConfigMountPath is where the installer mounts the guard-ldap-config
+// Secret inside the guard container when Options.ConfigFile is set.
+const LDAPConfigMountPath = "/etc/guard/ldap/config.yaml"
+
 // request to search user
 func (o *Options) newUserSearchRequest(username string) *ldap.SearchRequest {
+	if o.SearchMode == SearchModeActiveDirectory {
+		return o.newADUserSearchRequest(username)
+	}
 	userFilter := fmt.Sprintf("(&%s(%s=%s))", o.UserSearchFilter, o.UserAttribute, username)
 	return &ldap.SearchRequest{
 		BaseDN:       o.UserSearchDN,
@@ -111,7 +211,13 @@ func (o *Options) newUserSearchRequest(username string) *ldap.SearchRequest {
 
 // request to get user group list
 func (o *Options) newGroupSearchRequest(userDN string) *ldap.SearchRequest {
+	if o.SearchMode == SearchModeActiveDirectory {
+		return o.newADGroupSearchRequest(userDN)
+	}
 	groupFilter := fmt.Sprintf("(&%s(%s=%s))", o.GroupSearchFilter, o.GroupMemberAttribute, userDN)
+	if o.SearchMode == SearchModeFilter {
+		groupFilter = fmt.Sprintf(o.GroupSearchFilter, userDN)
+	}
 	return &ldap.SearchRequest{
 		BaseDN:       o.GroupSearchDN,
 		Scope:        ldap.ScopeWholeSubtree,
@@ -125,5 +231,14 @@ func (o *Options) newGroupSearchRequest(userDN string) *ldap.SearchRequest {
 }
 
 func (o *Options) Validate() []error {
-	return nil
+	var errs []error
+	switch o.SearchMode {
+	case "", SearchModeDirect, SearchModeFilter, SearchModeActiveDirectory:
+	default:
+		errs = append(errs, fmt.Errorf("invalid ldap.search-mode %q, must be one of: direct, filter, activedirectory", o.SearchMode))
+	}
+	if o.SearchMode == SearchModeFilter && !strings.Contains(o.GroupSearchFilter, "%s") {
+		errs = append(errs, fmt.Errorf("ldap.group-search-filter must contain a %%s placeholder for the user's DN when ldap.search-mode=filter, got %q", o.GroupSearchFilter))
+	}
+	return errs
 }
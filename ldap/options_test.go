@@ -0,0 +1,37 @@
+package ldap
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewGroupSearchRequestFilterModeUsesTemplateVerbatim(t *testing.T) {
+	o := &Options{
+		SearchMode:        SearchModeFilter,
+		GroupSearchFilter: "(|(objectClass=groupOfNames)(objectClass=groupOfUniqueNames)(member=%s))",
+		GroupMemberAttribute: "member", // must be ignored in filter mode
+	}
+	req := o.newGroupSearchRequest("cn=alice,dc=example,dc=com")
+	want := "(|(objectClass=groupOfNames)(objectClass=groupOfUniqueNames)(member=cn=alice,dc=example,dc=com))"
+	if req.Filter != want {
+		t.Errorf("newGroupSearchRequest filter = %q, want %q", req.Filter, want)
+	}
+}
+
+func TestValidateRequiresPlaceholderInFilterMode(t *testing.T) {
+	o := &Options{SearchMode: SearchModeFilter, GroupSearchFilter: "(objectClass=groupOfNames)"}
+	errs := o.Validate()
+	if len(errs) == 0 {
+		t.Fatalf("expected an error for a filter-mode GroupSearchFilter with no %%s placeholder")
+	}
+	if !strings.Contains(errs[0].Error(), "group-search-filter") {
+		t.Errorf("unexpected error: %v", errs[0])
+	}
+}
+
+func TestValidateAcceptsFilterModeWithPlaceholder(t *testing.T) {
+	o := &Options{SearchMode: SearchModeFilter, GroupSearchFilter: "(member=%s)"}
+	if errs := o.Validate(); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+}
@@ -0,0 +1,74 @@
+package ldap
+
+import (
+	"sort"
+	"strings"
+)
+
+// Registry dispatches an incoming username-qualified DN to the Backend whose
+// configured base DN is the longest matching suffix, so a single guard
+// server can front several LDAP trees at once.
+type Registry struct {
+	backends []*Backend
+	def      *Backend
+}
+
+// NewRegistry builds a Registry from the given backends. Backends are
+// evaluated longest-suffix-first, so more specific base DNs take precedence
+// over broader ones (e.g. "ou=eng,dc=example,dc=com" over "dc=example,dc=com").
+//
+// The backend used as the catch-all for Default is whichever has
+// Backend.Default set; if none does, it's the backend with the broadest
+// (shortest) BaseDN, not the most specific one — an unqualified username
+// should fall back to the broadest tree, not silently land in the most
+// narrowly scoped backend.
+func NewRegistry(backends ...*Backend) *Registry {
+	r := &Registry{backends: backends}
+	sort.SliceStable(r.backends, func(i, j int) bool {
+		return longestBaseDN(r.backends[i]) > longestBaseDN(r.backends[j])
+	})
+	for _, b := range r.backends {
+		if b.Default {
+			r.def = b
+			break
+		}
+	}
+	if r.def == nil && len(r.backends) > 0 {
+		r.def = r.backends[len(r.backends)-1]
+	}
+	return r
+}
+
+func longestBaseDN(b *Backend) int {
+	max := 0
+	for _, dn := range b.BaseDNs {
+		if len(dn) > max {
+			max = len(dn)
+		}
+	}
+	return max
+}
+
+// Lookup returns the Backend whose BaseDNs contains the longest suffix match
+// for dn, or false if no backend matches.
+func (r *Registry) Lookup(dn string) (*Backend, bool) {
+	dn = strings.ToLower(dn)
+	var best *Backend
+	bestLen := -1
+	for _, b := range r.backends {
+		for _, baseDN := range b.BaseDNs {
+			baseDN = strings.ToLower(baseDN)
+			if strings.HasSuffix(dn, baseDN) && len(baseDN) > bestLen {
+				best = b
+				bestLen = len(baseDN)
+			}
+		}
+	}
+	return best, best != nil
+}
+
+// Default returns the backend to use when a request carries no DN to route
+// on (e.g. a bare username). See NewRegistry for how it's chosen.
+func (r *Registry) Default() (*Backend, bool) {
+	return r.def, r.def != nil
+}
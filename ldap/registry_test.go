@@ -0,0 +1,75 @@
+package ldap
+
+import "testing"
+
+func TestRegistryLookupPicksLongestSuffix(t *testing.T) {
+	broad := &Backend{BaseDNs: []string{"dc=example,dc=com"}}
+	specific := &Backend{BaseDNs: []string{"ou=eng,dc=example,dc=com"}}
+	r := NewRegistry(broad, specific)
+
+	got, ok := r.Lookup("cn=alice,ou=eng,dc=example,dc=com")
+	if !ok {
+		t.Fatalf("expected a match")
+	}
+	if got != specific {
+		t.Fatalf("expected the more specific backend to win")
+	}
+
+	got, ok = r.Lookup("cn=bob,ou=sales,dc=example,dc=com")
+	if !ok {
+		t.Fatalf("expected a match")
+	}
+	if got != broad {
+		t.Fatalf("expected the broad backend to win when only it matches")
+	}
+}
+
+func TestRegistryLookupNoMatch(t *testing.T) {
+	r := NewRegistry(&Backend{BaseDNs: []string{"dc=example,dc=com"}})
+	if _, ok := r.Lookup("dc=other,dc=org"); ok {
+		t.Fatalf("expected no match")
+	}
+}
+
+func TestRegistryLookupIsCaseInsensitive(t *testing.T) {
+	r := NewRegistry(&Backend{BaseDNs: []string{"DC=Example,DC=Com"}})
+	if _, ok := r.Lookup("cn=alice,dc=example,dc=com"); !ok {
+		t.Fatalf("expected a case-insensitive match")
+	}
+}
+
+func TestRegistryDefault(t *testing.T) {
+	only := &Backend{BaseDNs: []string{"dc=example,dc=com"}}
+	r := NewRegistry(only)
+	got, ok := r.Default()
+	if !ok || got != only {
+		t.Fatalf("expected Default() to return the only registered backend")
+	}
+
+	empty := NewRegistry()
+	if _, ok := empty.Default(); ok {
+		t.Fatalf("expected no default backend for an empty registry")
+	}
+}
+
+func TestRegistryDefaultFallsBackToBroadestBaseDN(t *testing.T) {
+	broad := &Backend{BaseDNs: []string{"dc=example,dc=com"}}
+	specific := &Backend{BaseDNs: []string{"ou=eng,dc=example,dc=com"}}
+	r := NewRegistry(specific, broad)
+
+	got, ok := r.Default()
+	if !ok || got != broad {
+		t.Fatalf("expected the broadest (shortest) BaseDN backend to be the fallback default, got %+v", got)
+	}
+}
+
+func TestRegistryDefaultHonorsExplicitFlag(t *testing.T) {
+	broad := &Backend{BaseDNs: []string{"dc=example,dc=com"}}
+	specific := &Backend{BaseDNs: []string{"ou=eng,dc=example,dc=com"}, Default: true}
+	r := NewRegistry(broad, specific)
+
+	got, ok := r.Default()
+	if !ok || got != specific {
+		t.Fatalf("expected the explicitly marked backend to be the default, got %+v", got)
+	}
+}
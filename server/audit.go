@@ -0,0 +1,53 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// AuditRecord is the structured JSON record emitted per token review so
+// operators can answer "who authenticated as whom, with what groups, and
+// did it succeed" without reconstructing it from free-text logs.
+type AuditRecord struct {
+	Time      time.Time     `json:"time"`
+	RequestID string        `json:"requestID"`
+	Provider  string        `json:"provider"`
+	Username  string        `json:"username"`
+	Groups    []string      `json:"groups,omitempty"`
+	Outcome   string        `json:"outcome"` // "success" or "failure"
+	Reason    string        `json:"reason,omitempty"`
+	Latency   time.Duration `json:"latencyNs"`
+}
+
+// AuditLogger appends one JSON AuditRecord per line to a file, e.g. for
+// `tail -f` or a sidecar log shipper to consume.
+type AuditLogger struct {
+	mu   sync.Mutex
+	file *os.File
+	enc  *json.Encoder
+}
+
+// NewAuditLogger opens (creating if needed) the audit log at path for
+// appending. Passing an empty path is invalid; callers should only create an
+// AuditLogger when --server.audit-log-path is set.
+func NewAuditLogger(path string) (*AuditLogger, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return nil, err
+	}
+	return &AuditLogger{file: f, enc: json.NewEncoder(f)}, nil
+}
+
+// Record appends rec as a single JSON line.
+func (l *AuditLogger) Record(rec AuditRecord) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.enc.Encode(rec)
+}
+
+// Close closes the underlying audit log file.
+func (l *AuditLogger) Close() error {
+	return l.file.Close()
+}
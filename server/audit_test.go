@@ -0,0 +1,62 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAuditLoggerRecordAppendsJSONLines(t *testing.T) {
+	f, err := ioutil.TempFile("", "guard-audit-*.log")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	path := f.Name()
+	f.Close()
+	defer os.Remove(path)
+
+	l, err := NewAuditLogger(path)
+	if err != nil {
+		t.Fatalf("NewAuditLogger: %v", err)
+	}
+
+	want := []AuditRecord{
+		{Time: time.Now(), RequestID: "req-1", Provider: "ldap", Username: "alice", Groups: []string{"eng"}, Outcome: "success"},
+		{Time: time.Now(), RequestID: "req-2", Provider: "ldap", Username: "bob", Outcome: "failure", Reason: "invalid credentials"},
+	}
+	for _, rec := range want {
+		if err := l.Record(rec); err != nil {
+			t.Fatalf("Record: %v", err)
+		}
+	}
+	if err := l.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var got []AuditRecord
+	for scanner.Scan() {
+		var rec AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("Unmarshal: %v", err)
+		}
+		got = append(got, rec)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d audit lines, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].RequestID != want[i].RequestID || got[i].Outcome != want[i].Outcome {
+			t.Errorf("line %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,51 @@
+package server
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metric names are prefixed with guard_ so they're unambiguous alongside the
+// other exporters typically scraped on a cluster (kube-state-metrics,
+// node_exporter, etc).
+var (
+	AuthRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "guard_auth_requests_total",
+		Help: "Total number of TokenReview authentication requests handled, by provider and result.",
+	}, []string{"provider", "result"})
+
+	AuthDurationSeconds = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "guard_auth_duration_seconds",
+		Help:    "Time taken to resolve a TokenReview authentication request, by provider.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"provider"})
+
+	LDAPBindErrorsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "guard_ldap_bind_errors_total",
+		Help: "Total number of failed LDAP bind attempts (service account or per-user).",
+	})
+
+	LDAPSearchResults = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "guard_ldap_search_results",
+		Help:    "Number of groups returned per LDAP group-membership search.",
+		Buckets: []float64{0, 1, 2, 5, 10, 25, 50, 100, 250},
+	})
+)
+
+// RegisterMetrics registers guard's collectors on reg. Call this once during
+// server startup, before Serve begins handling /metrics.
+func RegisterMetrics(reg *prometheus.Registry) {
+	reg.MustRegister(
+		AuthRequestsTotal,
+		AuthDurationSeconds,
+		LDAPBindErrorsTotal,
+		LDAPSearchResults,
+	)
+}
+
+// ObserveAuthResult records an AuthRequestsTotal/AuthDurationSeconds sample
+// for a single TokenReview decision. result is expected to be "success" or
+// "failure".
+func ObserveAuthResult(provider, result string, durationSeconds float64) {
+	AuthRequestsTotal.WithLabelValues(provider, result).Inc()
+	AuthDurationSeconds.WithLabelValues(provider).Observe(durationSeconds)
+}
@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/spf13/pflag"
+)
+
+// MetricsOptions configures the observability endpoints added around the
+// auth paths: a Prometheus /metrics endpoint and a structured JSON audit
+// log of authentication decisions.
+type MetricsOptions struct {
+	// MetricsAddr is the address (host:port) the /metrics endpoint listens
+	// on. Empty disables the endpoint.
+	MetricsAddr string
+	// AuditLogPath, if set, is the file authentication decisions are
+	// appended to as JSON (see AuditRecord). Empty disables audit logging.
+	AuditLogPath string
+}
+
+func (o *MetricsOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&o.MetricsAddr, "server.metrics-addr", o.MetricsAddr, "Address (host:port) to serve Prometheus metrics on. Empty disables the /metrics endpoint.")
+	fs.StringVar(&o.AuditLogPath, "server.audit-log-path", o.AuditLogPath, "Path to append a JSON audit record to for every authentication decision. Empty disables audit logging.")
+}
+
+func (o MetricsOptions) ToArgs() []string {
+	var args []string
+	if o.MetricsAddr != "" {
+		args = append(args, fmt.Sprintf("--server.metrics-addr=%s", o.MetricsAddr))
+	}
+	if o.AuditLogPath != "" {
+		args = append(args, fmt.Sprintf("--server.audit-log-path=%s", AuditLogMountPath))
+	}
+	return args
+}
+
+// AuditLogMountPath is where the installer mounts the audit log volume
+// inside the guard container when MetricsOptions.AuditLogPath is set.
+const AuditLogMountPath = "/var/log/guard/audit.log"
+
+// MetricsPort is the container port the installer advertises for /metrics
+// when the port in --server.metrics-addr cannot be parsed.
+const MetricsPort = 8081
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/appscode/go/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// MetricsServer serves guard's Prometheus collectors on /metrics at
+// MetricsOptions.MetricsAddr.
+type MetricsServer struct {
+	httpServer *http.Server
+}
+
+// NewMetricsServer registers guard's collectors on a fresh registry and
+// builds a MetricsServer listening on opts.MetricsAddr. It returns nil if
+// opts.MetricsAddr is empty, so callers can unconditionally do:
+//
+//	if ms := server.NewMetricsServer(opts.Metrics); ms != nil {
+//		go ms.Run(stopCh)
+//	}
+func NewMetricsServer(opts MetricsOptions) *MetricsServer {
+	if opts.MetricsAddr == "" {
+		return nil
+	}
+	reg := prometheus.NewRegistry()
+	RegisterMetrics(reg)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	return &MetricsServer{httpServer: &http.Server{Addr: opts.MetricsAddr, Handler: mux}}
+}
+
+// Run starts serving /metrics and blocks until stopCh is closed, at which
+// point it shuts down gracefully.
+func (s *MetricsServer) Run(stopCh <-chan struct{}) {
+	go func() {
+		<-stopCh
+		s.httpServer.Shutdown(context.Background())
+	}()
+	if err := s.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Errorf("server: metrics server on %s failed: %v", s.httpServer.Addr, err)
+	}
+}
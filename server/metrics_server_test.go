@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+func TestNewMetricsServerReturnsNilWhenDisabled(t *testing.T) {
+	if ms := NewMetricsServer(MetricsOptions{}); ms != nil {
+		t.Fatalf("expected a nil MetricsServer when MetricsAddr is empty")
+	}
+}
+
+// TestMetricsHandlerServesRegisteredCollectors exercises the same
+// registration path NewMetricsServer uses (a fresh registry plus
+// RegisterMetrics) against an httptest server, since binding a real
+// MetricsAddr isn't appropriate in a unit test.
+func TestMetricsHandlerServesRegisteredCollectors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	RegisterMetrics(reg)
+	ObserveAuthResult("ldap", "success", 0.01)
+
+	ts := httptest.NewServer(promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want 200", resp.StatusCode)
+	}
+}
@@ -0,0 +1,56 @@
+package server
+
+import (
+	"time"
+
+	"github.com/appscode/go/log"
+)
+
+// AuthRecorder is the single call site a webhook handler invokes once per
+// TokenReview decision, so the Prometheus sample and the audit log line for
+// that decision can never be wired in partially: observing one without the
+// other would make guard_auth_requests_total and the audit log disagree
+// about how many authentications guard has handled.
+type AuthRecorder struct {
+	provider string
+	audit    *AuditLogger // nil when --server.audit-log-path is unset
+}
+
+// NewAuthRecorder builds an AuthRecorder for provider (e.g. "ldap", "azure",
+// "google"). audit may be nil, in which case only the Prometheus sample is
+// recorded.
+func NewAuthRecorder(provider string, audit *AuditLogger) *AuthRecorder {
+	return &AuthRecorder{provider: provider, audit: audit}
+}
+
+// Observe records the outcome of a single TokenReview decision: a
+// guard_auth_requests_total/guard_auth_duration_seconds sample, and — when
+// audit logging is enabled — an AuditRecord. authErr is the error returned
+// by the provider's authentication call, nil on success.
+func (r *AuthRecorder) Observe(requestID, username string, groups []string, started time.Time, authErr error) {
+	outcome := "success"
+	reason := ""
+	if authErr != nil {
+		outcome = "failure"
+		reason = authErr.Error()
+	}
+	latency := time.Since(started)
+	ObserveAuthResult(r.provider, outcome, latency.Seconds())
+
+	if r.audit == nil {
+		return
+	}
+	rec := AuditRecord{
+		Time:      time.Now(),
+		RequestID: requestID,
+		Provider:  r.provider,
+		Username:  username,
+		Groups:    groups,
+		Outcome:   outcome,
+		Reason:    reason,
+		Latency:   latency,
+	}
+	if err := r.audit.Record(rec); err != nil {
+		log.Errorf("server: failed to write audit record for request %s: %v", requestID, err)
+	}
+}
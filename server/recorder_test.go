@@ -0,0 +1,56 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAuthRecorderObserveWithoutAuditLogger(t *testing.T) {
+	r := NewAuthRecorder("ldap", nil)
+	// Must not panic when audit logging is disabled.
+	r.Observe("req-1", "alice", []string{"eng"}, time.Now(), nil)
+	r.Observe("req-2", "bob", nil, time.Now(), errors.New("invalid credentials"))
+}
+
+func TestAuthRecorderObserveWritesAuditRecord(t *testing.T) {
+	f, err := ioutil.TempFile("", "guard-audit-*.log")
+	if err != nil {
+		t.Fatalf("TempFile: %v", err)
+	}
+	path := f.Name()
+	f.Close()
+	defer os.Remove(path)
+
+	audit, err := NewAuditLogger(path)
+	if err != nil {
+		t.Fatalf("NewAuditLogger: %v", err)
+	}
+	r := NewAuthRecorder("ldap", audit)
+
+	r.Observe("req-1", "alice", []string{"eng"}, time.Now(), nil)
+	if err := audit.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() {
+		t.Fatalf("expected an audit line to be written")
+	}
+	var rec AuditRecord
+	if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if rec.RequestID != "req-1" || rec.Outcome != "success" || rec.Provider != "ldap" {
+		t.Errorf("unexpected audit record: %+v", rec)
+	}
+}